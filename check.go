@@ -0,0 +1,287 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/atom"
+)
+
+// Check validates the last run's deploy directory: every internal link and
+// asset reference in a deployed HTML file - href, src and srcset - must
+// name a deployed file, and its #fragment, if any, an id in it. An <embed>
+// left in deployed output is reported too, as Zas replaces every one it
+// handles. Run it after "zas generate", e.g. in CI before publishing.
+type Check struct {
+	// JSON makes Run report broken references as a JSON array instead of
+	// one line each.
+	JSON bool
+	// Out is where Run reports, os.Stdout if nil.
+	Out io.Writer
+}
+
+// BrokenRef is one broken reference Check found.
+type BrokenRef struct {
+	// Page is the deployed file holding the reference, slash-separated and
+	// relative to the deploy directory, and Source the file it was built
+	// from, per ManifestFile - empty if the manifest doesn't list it.
+	Page   string `json:"page"`
+	Source string `json:"source,omitempty"`
+	// Attr is the attribute the reference is in, or "embed" for a leftover
+	// <embed>, and Ref the reference itself.
+	Attr string `json:"attr"`
+	Ref  string `json:"ref"`
+	// Reason says what's wrong with it.
+	Reason string `json:"reason"`
+}
+
+func (ref BrokenRef) String() string {
+	page := ref.Page
+	if ref.Source != "" {
+		page = ref.Source + " (" + ref.Page + ")"
+	}
+	return fmt.Sprintf("%s: %s %q: %s", page, ref.Attr, ref.Ref, ref.Reason)
+}
+
+// Run checks the site in the current directory, reporting every broken
+// reference to Out. It fails if there's any.
+func (c *Check) Run() error {
+	cfg, err := NewConfig()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("not a valid Zas repository: %w", err)
+		}
+		return err
+	}
+	gen := &Generator{Config: cfg}
+	refs, err := gen.checkDeploy()
+	if err != nil {
+		return err
+	}
+	out := c.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	if c.JSON {
+		if refs == nil {
+			refs = []BrokenRef{}
+		}
+		data, err := json.MarshalIndent(refs, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err = out.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	} else {
+		for _, ref := range refs {
+			if _, err = fmt.Fprintln(out, ref); err != nil {
+				return err
+			}
+		}
+	}
+	if len(refs) > 0 {
+		return fmt.Errorf("%d broken reference(s)", len(refs))
+	}
+	return nil
+}
+
+// deployChecker holds what checkDeploy learns about the deploy directory.
+type deployChecker struct {
+	root string
+	// basePath is the site's base URL path, "/" for a site at its host's
+	// root, and host its host: a link to either is internal too.
+	basePath string
+	host     string
+	// ids caches each HTML file's ids, keyed by slash-separated path.
+	ids map[string]map[string]bool
+}
+
+// checkDeploy returns every broken reference in the deploy directory's
+// HTML files, in path and then document order.
+func (gen *Generator) checkDeploy() ([]BrokenRef, error) {
+	root := gen.GetDeployPath()
+	if _, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("nothing to check: %w", err)
+	}
+	c := &deployChecker{root: root, basePath: "/", ids: make(map[string]map[string]bool)}
+	if base, err := url.Parse(gen.Config.GetSection("site").GetString("baseurl")); err == nil {
+		c.host = base.Host
+		c.basePath = strings.TrimSuffix(base.Path, "/") + "/"
+	}
+	sources := make(map[string]string)
+	if data, err := os.ReadFile(ManifestFile); err == nil {
+		var m manifest
+		if json.Unmarshal(data, &m) == nil {
+			for output, entry := range m.Outputs {
+				sources[output] = entry.Source
+			}
+		}
+	}
+	var pages []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && isHTMLFile(p) {
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			pages = append(pages, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(pages)
+	var refs []BrokenRef
+	for _, page := range pages {
+		doc, err := c.parse(page)
+		if err != nil {
+			return nil, err
+		}
+		report := func(attr, ref, reason string) {
+			refs = append(refs, BrokenRef{Page: page, Source: sources[page], Attr: attr, Ref: ref, Reason: reason})
+		}
+		doc.Find(atom.Embed.String()).Each(func(_ int, e *goquery.Selection) {
+			report("embed", e.AttrOr(atom.Src.String(), ""), "left unprocessed")
+		})
+		doc.Find("[href], [src], [srcset]").Not(atom.Embed.String()).Each(func(_ int, e *goquery.Selection) {
+			for _, attr := range []string{atom.Href.String(), atom.Src.String()} {
+				if ref, ok := e.Attr(attr); ok {
+					if reason := c.check(page, ref); reason != "" {
+						report(attr, ref, reason)
+					}
+				}
+			}
+			if srcset, ok := e.Attr(atom.Srcset.String()); ok {
+				for _, candidate := range strings.Split(srcset, ",") {
+					fields := strings.Fields(candidate)
+					if len(fields) == 0 {
+						continue
+					}
+					if reason := c.check(page, fields[0]); reason != "" {
+						report(atom.Srcset.String(), fields[0], reason)
+					}
+				}
+			}
+		})
+	}
+	return refs, nil
+}
+
+// isHTMLFile reports whether p is an HTML file, by its extension.
+func isHTMLFile(p string) bool {
+	return hasExtension(p, ".html") || hasExtension(p, ".htm")
+}
+
+// parse parses the deployed HTML file at page, a slash-separated path
+// relative to c.root, and caches its ids.
+func (c *deployChecker) parse(page string) (*goquery.Document, error) {
+	f, err := os.Open(filepath.Join(c.root, filepath.FromSlash(page)))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", page, err)
+	}
+	ids := make(map[string]bool)
+	doc.Find("[id], a[name]").Each(func(_ int, e *goquery.Selection) {
+		if id, ok := e.Attr("id"); ok {
+			ids[id] = true
+		}
+		if name, ok := e.Attr("name"); ok && goquery.NodeName(e) == atom.A.String() {
+			ids[name] = true
+		}
+	})
+	c.ids[page] = ids
+	return doc, nil
+}
+
+// check returns why ref, found in page, is broken, or "" if it isn't or
+// points outside the site.
+func (c *deployChecker) check(page, ref string) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "unparseable: " + err.Error()
+	}
+	if u.Opaque != "" || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+		// mailto:, data:, javascript: and the like.
+		return ""
+	}
+	if u.Host != "" && u.Host != c.host {
+		return ""
+	}
+	target := page
+	switch {
+	case u.Path == "":
+		// Same page: only the fragment to check.
+	case strings.HasPrefix(u.Path, "/"):
+		rel, ok := strings.CutPrefix(u.Path+"/", c.basePath)
+		if !ok {
+			return "outside the site's base URL " + c.basePath
+		}
+		target = path.Clean(strings.TrimSuffix(rel, "/"))
+	default:
+		target = path.Join(path.Dir(page), u.Path)
+	}
+	if u.Path != "" && (strings.HasSuffix(u.Path, "/") || target == "" || target == ".") {
+		target = path.Join(target, "index.html")
+	}
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "outside the deploy directory"
+	}
+	info, err := os.Stat(filepath.Join(c.root, filepath.FromSlash(target)))
+	if err == nil && info.IsDir() {
+		target = path.Join(target, "index.html")
+		info, err = os.Stat(filepath.Join(c.root, filepath.FromSlash(target)))
+	}
+	if err != nil {
+		return "no such file " + target
+	}
+	if u.Fragment == "" || !isHTMLFile(target) {
+		return ""
+	}
+	ids, ok := c.ids[target]
+	if !ok {
+		if _, err := c.parse(target); err != nil {
+			return "unreadable: " + err.Error()
+		}
+		ids = c.ids[target]
+	}
+	if !ids[u.Fragment] {
+		return "no id " + u.Fragment + " in " + target
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCheckReportsBrokenReferences(t *testing.T) {
+	newTestSite(t, "check-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	var out bytes.Buffer
+	c := Check{Out: &out}
+	err := c.Run()
+	if err == nil || !strings.Contains(err.Error(), "4 broken reference(s)") {
+		t.Fatalf("Check.Run() error = %v, want 4 broken references", err)
+	}
+	want := `index.html (index.html): srcset "img/logo@2x.gif": no such file img/logo@2x.gif
+index.html (index.html): href "docs/gone.html": no such file docs/gone.html
+index.html (index.html): href "docs/guide.html#teardown": no id teardown in docs/guide.html
+index.html (index.html): href "#nowhere": no id nowhere in index.html
+`
+	if got := out.String(); got != want {
+		t.Errorf("Check.Run() output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCheckReportsJSON(t *testing.T) {
+	newTestSite(t, "check-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	var out bytes.Buffer
+	c := Check{JSON: true, Out: &out}
+	if err := c.Run(); err == nil {
+		t.Fatal("Check.Run() error = nil, want broken references")
+	}
+	var refs []BrokenRef
+	if err := json.Unmarshal(out.Bytes(), &refs); err != nil {
+		t.Fatalf("Check.Run() output %q isn't JSON: %v", out.String(), err)
+	}
+	if len(refs) != 4 || refs[1] != (BrokenRef{Page: "index.html", Source: "index.html", Attr: "href", Ref: "docs/gone.html", Reason: "no such file docs/gone.html"}) {
+		t.Errorf("Check.Run() refs = %+v, want the broken link to docs/gone.html second of 4", refs)
+	}
+}
+
+func TestCheckPassesCleanSite(t *testing.T) {
+	newTestSite(t, "check-site")
+	index, err := os.ReadFile("index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keep the first three lines, whose references all resolve.
+	clean := strings.Join(strings.SplitAfter(string(index), "\n")[:3], "")
+	if err := os.WriteFile("index.html", []byte(clean), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	var out bytes.Buffer
+	c := Check{JSON: true, Out: &out}
+	if err := c.Run(); err != nil || out.String() != "[]\n" {
+		t.Errorf("Check.Run() = %v, output %q, want nil and an empty array", err, out.String())
+	}
+}
+
+func TestCheckReportsLeftoverEmbed(t *testing.T) {
+	newTestSite(t, "check-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if err := os.WriteFile(".zas/deploy/stray.html", []byte(`<p><embed src="nav.md" type="text/markdown"></p>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	c := Check{Out: &out}
+	_ = c.Run()
+	if want := `stray.html: embed "nav.md": left unprocessed`; !strings.Contains(out.String(), want) {
+		t.Errorf("Check.Run() output = %q, want %q", out.String(), want)
+	}
+}
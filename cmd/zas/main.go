@@ -36,18 +36,43 @@ import (
 var subcommands = []*zas.Subcommand{
 	cmdInit,
 	cmdGenerate,
+	cmdServe,
+	cmdCheck,
+	cmdHighlight,
 	cmdHelp,
 	cmdVersion,
 }
 
 var (
-	verbose, full, noPlugins, force *bool
-	cmdInit                         = zas.NewSubcommand("init - create a new Zas site in the current directory", func() error {
+	verbose, full, noPlugins, force, drafts, future *bool
+	cmdInit                                         = zas.NewSubcommand("init - create a new Zas site in the current directory", func() error {
 		i := zas.Init{Force: *force}
 		return i.Run()
 	})
 	cmdGenerate = zas.NewSubcommand("generate - render the site from source into the deploy directory", func() error {
-		return zas.NewGenerator(*verbose, *full, *noPlugins).Run()
+		gen := zas.NewGenerator(*verbose, *full, *noPlugins)
+		gen.Drafts, gen.Future = *drafts, *future
+		return gen.Run()
+	})
+	serveAddr                                              *string
+	serveVerbose, serveNoPlugins, serveDrafts, serveFuture *bool
+	cmdServe                                               = zas.NewSubcommand("serve - preview the site over HTTP, regenerating and reloading on every change", func() error {
+		s := zas.Server{Addr: *serveAddr, Verbose: *serveVerbose, NoPlugins: *serveNoPlugins, Drafts: *serveDrafts, Future: *serveFuture}
+		return s.Run()
+	})
+	checkJSON *bool
+	cmdCheck  = zas.NewSubcommand("check - report broken internal links and assets in the deploy directory", func() error {
+		c := zas.Check{JSON: *checkJSON}
+		return c.Run()
+	})
+	highlightTheme *string
+	cmdHighlight   = zas.NewSubcommand("highlight - print the CSS theme for syntax-highlighted code blocks", func() error {
+		css, err := zas.HighlightCSS(*highlightTheme)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(css)
+		return err
 	})
 	// cmdHelp and cmdVersion get their Run funcs wired up in init() below,
 	// rather than inline here: both printUsage and printVersion end up
@@ -64,6 +89,15 @@ func init() {
 	verbose = cmdGenerate.Flag.Bool("verbose", false, "Verbose output")
 	full = cmdGenerate.Flag.Bool("full", false, "Full generation (non-incremental mode)")
 	noPlugins = cmdGenerate.Flag.Bool("no-plugins", false, "Disable content-triggered plugin execution: <embed> MIME-type plugins and application/zas+ script tags (see README's \"Plugins\" section)")
+	drafts = cmdGenerate.Flag.Bool("drafts", false, "Publish draft pages (\"draft: true\" in their config)")
+	future = cmdGenerate.Flag.Bool("future", false, "Publish pages scheduled for later (a \"publish_date\" or \"date\" still to come)")
+	serveAddr = cmdServe.Flag.String("addr", zas.DefaultServeAddr, "TCP address to listen on")
+	serveVerbose = cmdServe.Flag.Bool("verbose", false, "Verbose output")
+	serveNoPlugins = cmdServe.Flag.Bool("no-plugins", false, "Disable content-triggered plugin execution, as for generate")
+	serveDrafts = cmdServe.Flag.Bool("drafts", false, "Publish draft pages, as for generate")
+	serveFuture = cmdServe.Flag.Bool("future", false, "Publish pages scheduled for later, as for generate")
+	checkJSON = cmdCheck.Flag.Bool("json", false, "Report broken references as a JSON array")
+	highlightTheme = cmdHighlight.Flag.String("theme", zas.DefaultHighlightTheme, "Theme to print: "+strings.Join(zas.HighlightThemes(), " or "))
 	force = cmdInit.Flag.Bool("force", false, "Overwrite an existing config.yml/layout.html with scaffolded defaults instead of leaving them untouched")
 
 	cmdHelp.Run = func() error {
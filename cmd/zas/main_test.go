@@ -23,6 +23,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/darccio/zas"
 )
 
 func writeStubPlugin(t *testing.T, dir, name, script string) {
@@ -226,6 +228,47 @@ func TestRunVersion(t *testing.T) {
 	}
 }
 
+// TestRunHighlightPrintsTheme covers "zas highlight": the default theme
+// on stdout, another one with -theme, and an unknown one as an error.
+func TestRunHighlightPrintsTheme(t *testing.T) {
+	for theme, want := range map[string]string{"": "light theme", "dark": "dark theme"} {
+		// Flag values outlive a run: reset -theme, or leaving it out would
+		// mean whatever an earlier case gave.
+		*highlightTheme = zas.DefaultHighlightTheme
+		args := []string{"highlight"}
+		if theme != "" {
+			args = append(args, "-theme", theme)
+		}
+		var code int
+		out := captureOutput(t, &os.Stdout, func() {
+			code = run(args)
+		})
+		if code != 0 || !strings.Contains(out, want) || !strings.Contains(out, ".hl-keyword {") {
+			t.Errorf("run(%q) = %d, stdout %q, want 0 and the %s", args, code, out, want)
+		}
+	}
+	var code int
+	captureOutput(t, &os.Stderr, func() {
+		code = run([]string{"highlight", "-theme", "neon"})
+	})
+	if code == 0 {
+		t.Errorf("run() = 0 for an unknown theme, want a failure")
+	}
+}
+
+// TestRunCheckOutsideASite covers "zas check" failing, not passing, where
+// there's no site to check.
+func TestRunCheckOutsideASite(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var code int
+	out := captureOutput(t, &os.Stderr, func() {
+		code = run([]string{"check"})
+	})
+	if code != 1 || !strings.Contains(out, "not a valid Zas repository") {
+		t.Errorf("run(check) = %d, stderr %q, want 1 and a missing site error", code, out)
+	}
+}
+
 // TestRunPluginDispatchIsCaseInsensitive covers the casing-consistency fix:
 // internal dispatch already lower-cases args[0] before matching against the
 // subcommands table, so plugin dispatch must fold the command name the same
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// codeBlocksKey is the section of ConfigFile mapping a fenced code block's
+// language, its info string, to the zs plugin rendering it:
+//
+//	codeblocks:
+//	  mermaid: diagrams
+//	  chart: vega
+//
+// runs zsdiagrams for every ```mermaid block.
+const codeBlocksKey = "codeblocks"
+
+// codeLanguagePrefix marks a <code> element's language class, as goldmark
+// writes it for a fenced code block.
+const codeLanguagePrefix = "language-"
+
+/*
+ * Handles fenced code blocks whose language is mapped to a plugin under
+ * codeBlocksKey: the block's text is piped to zs<name> the same way a
+ * script tag's is (see handleScriptPlugin), and its stdout replaces the
+ * whole <pre> as HTML. Blocks in any other language are left to render as
+ * code.
+ *
+ * The block's text is its code element's, entities decoded and any
+ * highlighting spans dropped, so the plugin reads exactly what was written
+ * between the fences.
+ */
+func (gen *Generator) handleCodeBlockPlugins(doc *goquery.Document) (err error) {
+	plugins := gen.Config.GetSection(codeBlocksKey)
+	if len(plugins) == 0 {
+		return nil
+	}
+	doc.Find("pre > code").EachWithBreak(func(_ int, code *goquery.Selection) bool {
+		lang := codeLanguage(code)
+		if lang == "" {
+			return true
+		}
+		if _, ok := plugins[lang]; !ok {
+			return true
+		}
+		name := plugins.GetString(lang)
+		if !pluginNameRe.MatchString(name) {
+			err = fmt.Errorf("no valid plugin configured for code block language %q", lang)
+			return false
+		}
+		if gen.NoPlugins {
+			err = fmt.Errorf("plugin execution disabled (-no-plugins): code block language %q needs plugin %s%s", lang, PluginPrefix, name)
+			return false
+		}
+		err = gen.pipeToPlugin(code.Parent(), name, nil, code.Text(), fmt.Sprintf("code block language %q", lang))
+		return err == nil
+	})
+	return
+}
+
+// codeLanguage returns the language of a code element, from its first
+// "language-" class, or "" for one without.
+func codeLanguage(code *goquery.Selection) string {
+	for _, class := range strings.Fields(code.AttrOr("class", "")) {
+		if lang, ok := strings.CutPrefix(class, codeLanguagePrefix); ok {
+			return lang
+		}
+	}
+	return ""
+}
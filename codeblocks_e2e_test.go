@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRoutesCodeBlockToPlugin(t *testing.T) {
+	installStub(t, "zswrap", zsWrapStub)
+	newTestSite(t, "codeblocks-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	got := readDeploy(t, "index.html")
+	if want := "<pre>graph TD; A --&gt; B &amp; C\n</pre>"; !strings.Contains(got, want) {
+		t.Errorf("deployed index.html = %q, want the zswrap plugin's output %q", got, want)
+	}
+	if strings.Contains(got, "language-mermaid") {
+		t.Errorf("deployed index.html = %q, want the mermaid block replaced", got)
+	}
+	if !strings.Contains(got, `<code class="language-go">`) {
+		t.Errorf("deployed index.html = %q, want the go block left as code", got)
+	}
+}
+
+func TestGenerateNoPluginsRefusesCodeBlockPlugin(t *testing.T) {
+	installStub(t, "zswrap", zsWrapStub)
+	newTestSite(t, "codeblocks-site")
+	err := generate(t, noPluginsGen)
+	if err == nil {
+		t.Fatal("generate() with NoPlugins set: want error, got nil")
+	}
+	if want := `code block language "mermaid" needs plugin zswrap`; !strings.Contains(err.Error(), "-no-plugins") || !strings.Contains(err.Error(), want) {
+		t.Fatalf("generate() error = %v, want it to mention -no-plugins and %q", err, want)
+	}
+	assertDeployMissing(t, "index.html")
+}
+
+func TestGenerateRejectsInvalidCodeBlockPluginName(t *testing.T) {
+	installStub(t, "zswrap", zsWrapStub)
+	newTestSite(t, "codeblocks-site")
+	appendConfig(t, "  chart: ../../evil\n")
+	if err := os.WriteFile("chart.md", []byte("```chart\n{}\n```\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := generate(t)
+	if want := `no valid plugin configured for code block language "chart"`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("generate() error = %v, want it to mention %q", err, want)
+	}
+}
@@ -68,6 +68,25 @@ var (
 	LayoutFile = filepath.Join(Dir, "layout.html")
 )
 
+// LayoutsDir holds the named layouts a page or directory can pick instead
+// of LayoutFile, with a "layout" key in its page config or DirConfigFile.
+var LayoutsDir = filepath.Join(Dir, "layouts")
+
+// PartialsDir holds the shared templates - headers, footers, meta tags -
+// parsed into every layout's template set, so any layout can pull one in
+// with {{template}} or override one it {{define}}s with a {{block}}.
+var PartialsDir = filepath.Join(Dir, "partials")
+
+// DataDir holds the YAML, JSON and CSV data files templates read with
+// {{.Data}}, e.g. a team list or a pricing table.
+var DataDir = filepath.Join(Dir, "data")
+
+// ManifestFile is where generate records what each deploy output was built
+// from (see manifest.go), so the next incremental run can tell which
+// outputs went stale through something other than their own source.
+// Unlike ConfigFile and friends, it's written by Zas itself, never by hand.
+var ManifestFile = filepath.Join(Dir, "manifest.json")
+
 // defaultConfig is the built-in configuration merged into every site's own
 // config.yml (see NewConfig in init.go). It is unexported, with DefaultConfig
 // as the only way to read it, so external code can neither reassign it nor -
@@ -67,6 +67,16 @@ type ZasData struct {
 	Page map[interface{}]interface{}
 	// Current directory configuration, from DirConfigFile.
 	Directory ConfigSection
+	// On a taxonomy's index and term pages, the taxonomy and, on a term's
+	// page, the term they list (see Taxonomy). Nil on every other page.
+	Taxonomy *Taxonomy
+	Term     *Term
+	// On a page declaring "paginate", the page of its listing being
+	// rendered (see Paginator). Nil on every other page.
+	Paginator *Paginator
+	// The page's table of contents, from the headings render gave ids to.
+	// Like FirstTitle, the page's own body only gets a preview of it.
+	TOC TableOfContents
 	// Config loaded from ConfigFile.
 	config ConfigSection
 	// i18n helper
@@ -75,6 +85,8 @@ type ZasData struct {
 	// onto the layout's <body> element since Body only carries the source
 	// body's inner HTML, not the element itself.
 	bodyAttrs map[string]string
+	// Body as feeds list it: without the permalinks heading_anchors adds.
+	feedBody thtml.HTML
 	// Tracks embed nesting depth for this render, guarding against a self-
 	// or mutually-embedding file recursing without bound.
 	embedDepth int
@@ -95,6 +107,22 @@ type ZasData struct {
 	// embeds (e.g. a site-wide footer) intentionally keep resolving
 	// site-root-relative regardless of which page is currently rendering.
 	embedBaseDir string
+	// embedFile is the file being parsed: the page itself, or the file
+	// Markdown or Html is embedding, saved and restored like embedBaseDir.
+	// Relative links to Markdown sources resolve against it (see
+	// rewriteSourceLinks).
+	embedFile string
+	// Every file this render pulled in through one of Zas's own embed
+	// handlers, recorded in ManifestFile so a later incremental run can
+	// rebuild this page when only an embedded file changed.
+	embeds embedSet
+	// The site-wide page index behind Pages and Section, and whether this
+	// render used it - recorded in ManifestFile so a later incremental run
+	// rebuilds this page when the index changes.
+	index     *pageIndex
+	usesIndex bool
+	// DataDir's decoded files, behind Data.
+	data map[string]interface{}
 }
 
 // ZasSiteData is the site configuration.
@@ -103,6 +131,9 @@ type ZasData struct {
 type ZasSiteData struct {
 	BaseURL string
 	Image   string
+	// Params is the whole "site" section, for any other key in it, e.g.
+	// {{.Site.Params.title}}.
+	Params ConfigSection
 }
 
 // Title returns the current title, from page's config and first level
@@ -128,6 +159,21 @@ func (zd *ZasData) URL() string {
 // and when the final key is missing or isn't a string - the two ways a
 // keypath can fail to resolve to a real value.
 func (zd *ZasData) Extra(keypath string) (value string, err error) {
+	raw, err := zd.ExtraValue(keypath)
+	if err != nil {
+		return
+	}
+	var ok bool
+	if value, ok = raw.(string); !ok {
+		err = errors.New("not found")
+	}
+	return
+}
+
+// ExtraValue is like Extra, but returns the value as decoded from
+// ConfigFile - a list, a map, a number or a bool as much as a string - so
+// templates can range over or test it.
+func (zd *ZasData) ExtraValue(keypath string) (value interface{}, err error) {
 	keypath = path.Clean(keypath)
 	if path.IsAbs(keypath) {
 		keypath = keypath[1:]
@@ -144,7 +190,7 @@ func (zd *ZasData) Extra(keypath string) (value string, err error) {
 		}
 	}
 	var ok bool
-	if value, ok = section.GetStringOK(key); !ok {
+	if value, ok = section[key]; !ok {
 		err = errors.New("not found")
 	}
 	return
@@ -161,19 +207,10 @@ func (zd *ZasData) Language() (string, error) {
 // "language:" with no value, or a numeric value) — a genuinely absent key
 // still falls back to Extra's own lenient "" default.
 func (zd *ZasData) Resolve(id string) (string, error) {
-	var (
-		value interface{}
-		ok    bool
-	)
-	value, ok = zd.Page[id]
+	value, ok := zd.localValue(id)
 	if !ok {
-		if zd.Directory != nil {
-			value, ok = zd.Directory[id]
-		}
-		if !ok {
-			s, _ := zd.Extra("/site/" + id)
-			return s, nil
-		}
+		s, _ := zd.Extra("/site/" + id)
+		return s, nil
 	}
 	s, isString := value.(string)
 	if !isString {
@@ -182,6 +219,57 @@ func (zd *ZasData) Resolve(id string) (string, error) {
 	return s, nil
 }
 
+// ResolveValue is like Resolve, but returns id's value whatever its type,
+// or nil when it's set nowhere.
+func (zd *ZasData) ResolveValue(id string) (interface{}, error) {
+	if value, ok := zd.localValue(id); ok {
+		return value, nil
+	}
+	value, _ := zd.ExtraValue("/site/" + id)
+	return value, nil
+}
+
+// ResolveList is like ResolveValue for a list - of authors, links, ... -
+// to range over. It errors when id is set to anything but a list, and
+// returns nil when it's set nowhere.
+func (zd *ZasData) ResolveList(id string) ([]interface{}, error) {
+	value, _ := zd.ResolveValue(id)
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("config value %q must be a list, got %T", id, value)
+	}
+	return list, nil
+}
+
+// ResolveBool is like ResolveValue for a bool. It errors when id is set to
+// anything but a bool, and returns false when it's set nowhere.
+func (zd *ZasData) ResolveBool(id string) (bool, error) {
+	value, _ := zd.ResolveValue(id)
+	if value == nil {
+		return false, nil
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("config value %q must be a bool, got %T", id, value)
+	}
+	return b, nil
+}
+
+// localValue returns id's value from Page or else Directory, the two
+// levels that override the site config.
+func (zd *ZasData) localValue(id string) (value interface{}, ok bool) {
+	if value, ok = zd.Page[id]; ok {
+		return
+	}
+	if zd.Directory != nil {
+		value, ok = zd.Directory[id]
+	}
+	return
+}
+
 // E translates s for the page's resolved language, falling back to
 // "**s**" when no translation is found.
 func (zd *ZasData) E(s string, a ...interface{}) (t string, err error) {
@@ -210,11 +298,12 @@ func (zd *ZasData) IsHome() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return zd.Path == "/index.html" || zd.Path == fmt.Sprintf("/%s/index.html", lang), nil
+	return zd.Path == "/index.html" || zd.Path == "/" || zd.Path == fmt.Sprintf("/%s/index.html", lang) || zd.Path == fmt.Sprintf("/%s/", lang), nil
 }
 
 // NewZasData builds a ZasData for the page at srcPath.
 func NewZasData(srcPath string, gen *Generator) (data ZasData) {
+	source := srcPath
 	// Any path must finish in ".html".
 	srcPath = swapExtension(srcPath, ".md", ".html")
 	// filepath.Walk (the only caller) yields srcPath with the OS's own
@@ -224,12 +313,21 @@ func NewZasData(srcPath string, gen *Generator) (data ZasData) {
 	// hardcoded "/"-separated comparisons below, so a language-prefixed
 	// home page can still be recognized as one there too).
 	data.Path = "/" + filepath.ToSlash(srcPath)
+	// A listed page's Path is the one discoverPages settled on, pretty or
+	// not (see prettyURLs); the index isn't built yet while discoverPages
+	// itself runs, nor has an entry for a generated page's path.
+	if page := gen.index.page(source); page != nil {
+		data.Path = page.Path
+	}
 	// srcPath (not data.Path) here: it still has the OS's own separator,
 	// which is what resolveEmbedSrc's filepath.Join expects, and its
 	// directory portion is identical either way - swapExtension above only
 	// ever rewrites the final path component's extension.
 	data.embedBaseDir = filepath.Dir(srcPath)
+	data.embedFile = source
 	data.config = gen.Config
+	data.index = gen.index
+	data.data = gen.data
 	// Each ZasData gets its own i18n.Build sharing the (read-only, post-init)
 	// Index, so per-render SetTarget/Translate calls don't race or bleed
 	// across languages on a Build shared by every render goroutine.
@@ -239,5 +337,6 @@ func NewZasData(srcPath string, gen *Generator) (data ZasData) {
 	}
 	data.Site.BaseURL = gen.Config.GetSection("site").GetString("baseurl")
 	data.Site.Image = gen.Config.GetSection("site").GetString("image")
+	data.Site.Params = gen.Config.GetSection("site")
 	return
 }
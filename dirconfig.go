@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"dario.cat/mergo"
+)
+
+// Directory config modes, chosen with the zas section's "dirconfig" key:
+//
+//	zas:
+//	  dirconfig: merge
+//
+// dirConfigNearest, the default, gives a page only the nearest
+// DirConfigFile up its directory tree, as it always has. dirConfigMerge
+// deep-merges each DirConfigFile over its ancestors' instead, so a nested
+// one only needs the keys it changes.
+const (
+	dirConfigNearest = "nearest"
+	dirConfigMerge   = "merge"
+)
+
+// cascadeKey is the DirConfigFile section whose keys are page config
+// defaults for every page below it, whatever the directory config mode.
+// Nested cascades deep-merge over their ancestors', and a page's own
+// config wins over all of them.
+const cascadeKey = "cascade"
+
+// checkDirConfig validates the configured directory config mode.
+func (gen *Generator) checkDirConfig() error {
+	switch mode := gen.Config.GetZString("dirconfig"); mode {
+	case "", dirConfigNearest, dirConfigMerge:
+		return nil
+	default:
+		return fmt.Errorf("%s: unknown dirconfig mode %q (want %q or %q)", ConfigFile, mode, dirConfigNearest, dirConfigMerge)
+	}
+}
+
+// mergeDirConfigs reports whether merged directory configs are configured.
+func (gen *Generator) mergeDirConfigs() bool {
+	return gen.Config.GetZString("dirconfig") == dirConfigMerge
+}
+
+// inheritDirConfig completes entry, just read from its own DirConfigFile,
+// with parent, the entry of its directory's parent. entry always inherits
+// parent's cascade. In merge mode it inherits the rest of parent's config
+// too, except feeds, which belong to the directory declaring them. entry
+// then depends on every DirConfigFile parent did as well as its own.
+func (gen *Generator) inheritDirConfig(entry, parent dirConfigEntry) (dirConfigEntry, error) {
+	var err error
+	if entry.cascade, err = mergeConfig(entry.config.GetSection(cascadeKey), parent.cascade); err != nil {
+		return entry, err
+	}
+	if gen.mergeDirConfigs() && parent.config != nil {
+		own := entry.config
+		if entry.config, err = mergeConfig(own, parent.config); err != nil {
+			return entry, err
+		}
+		// Feeds are declared by the directory they list, not inherited: a
+		// nested DirConfigFile would otherwise re-declare its parent's.
+		if _, ok := own[feedsKey]; !ok {
+			delete(entry.config, feedsKey)
+		}
+	}
+	if parent.modTime.After(entry.modTime) {
+		entry.modTime = parent.modTime
+	}
+	entry.files = append(append([]string(nil), parent.files...), entry.files...)
+	return entry, nil
+}
+
+// mergeConfig deep-merges base under over, the way NewConfig merges the
+// defaults under ConfigFile: over's keys win, and a map in both is merged
+// key by key. Neither is modified, and the result shares no map with
+// either, since both live on in the directory config cache.
+func mergeConfig(over, base ConfigSection) (ConfigSection, error) {
+	if over == nil && base == nil {
+		return nil, nil
+	}
+	merged := cloneConfig(over)
+	if merged == nil {
+		merged = ConfigSection{}
+	}
+	if err := mergo.Merge(&merged, cloneConfig(base)); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// cloneConfig deep-copies section's maps.
+func cloneConfig(section ConfigSection) ConfigSection {
+	if section == nil {
+		return nil
+	}
+	clone := make(ConfigSection, len(section))
+	for key, value := range section {
+		switch value := value.(type) {
+		case ConfigSection:
+			clone[key] = cloneConfig(value)
+		case map[string]interface{}:
+			clone[key] = map[string]interface{}(cloneConfig(value))
+		default:
+			clone[key] = value
+		}
+	}
+	return clone
+}
+
+// withCascade returns page, the config of the page at path, with every
+// cascaded key applying to it that page doesn't set itself.
+func (gen *Generator) withCascade(path string, page map[interface{}]interface{}) map[interface{}]interface{} {
+	_, _, _ = gen.loadZasDirectoryConfig(path)
+	entry, _ := gen.getCachedDirConfig(filepath.Dir(path))
+	if len(entry.cascade) == 0 {
+		return page
+	}
+	if page == nil {
+		page = make(map[interface{}]interface{}, len(entry.cascade))
+	}
+	for key, value := range entry.cascade {
+		if _, ok := page[key]; !ok {
+			page[key] = value
+		}
+	}
+	return page
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDirConfigNearestByDefault(t *testing.T) {
+	newTestSite(t, "dirconfig-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, filepath.Join("docs", "guides", "page.html"))
+	for _, want := range []string{`<p class="dir">||blue</p>`, `<p class="page">Ada|one|two</p>`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("docs/guides/page.html = %q, want %q", out, want)
+		}
+	}
+	if out, want := readDeploy(t, filepath.Join("docs", "own.html")), `<p class="page">Grace</p>`; !strings.Contains(out, want) {
+		t.Errorf("docs/own.html = %q, want the page's own config to win: %q", out, want)
+	}
+}
+
+func TestDirConfigMerge(t *testing.T) {
+	newTestSite(t, "dirconfig-site")
+	setZasOption(t, "dirconfig", "merge")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, filepath.Join("docs", "guides", "page.html"))
+	for _, want := range []string{`<p class="dir">es|docs|blue</p>`, `<p class="page">Ada|one|two</p>`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("docs/guides/page.html = %q, want %q", out, want)
+		}
+	}
+}
+
+func TestDirConfigUnknownMode(t *testing.T) {
+	newTestSite(t, "dirconfig-site")
+	setZasOption(t, "dirconfig", "deep")
+	if err := generate(t); err == nil || !strings.Contains(err.Error(), "unknown dirconfig mode") {
+		t.Fatalf("generate() error = %v, want an unknown mode error", err)
+	}
+}
+
+func TestIncrementalRebuildsWhenAncestorDirConfigChanges(t *testing.T) {
+	newTestSite(t, "dirconfig-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	parent := filepath.Join("docs", DirConfigFile)
+	if err := os.WriteFile(parent, []byte("cascade:\n  author: Linus\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchFuture(t, parent)
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out, want := readDeploy(t, filepath.Join("docs", "guides", "page.html")), `<p class="page">Linus|`; !strings.Contains(out, want) {
+		t.Fatalf("docs/guides/page.html = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+// End-to-end tests proving an incremental run rebuilds exactly the pages
+// whose embed closure changed, through ManifestFile.
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rewriteFuture replaces old with new in path and pushes its mtime past the
+// deploy output the previous generate wrote.
+func rewriteFuture(t *testing.T, path, old, new string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := strings.Replace(string(data), old, new, 1)
+	if updated == string(data) {
+		t.Fatalf("%s has no %q to replace", path, old)
+	}
+	if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchFuture(t, path)
+}
+
+func deployModTime(t *testing.T, rel string) time.Time {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(".zas", "deploy", rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.ModTime()
+}
+
+func TestIncrementalRebuildsPageWhenEmbedChanges(t *testing.T) {
+	newTestSite(t, "site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	// Pin the untouched page's output in the past, so a rebuild would be
+	// visible as a newer mtime regardless of filesystem resolution.
+	past := time.Now().Add(-time.Minute)
+	aboutPath := filepath.Join(".zas", "deploy", "about.html")
+	if err := os.Chtimes(aboutPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	rewriteFuture(t, filepath.Join("partials", "nav.html"), "Home", "Start")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+
+	if out := readDeploy(t, "index.html"); !strings.Contains(out, ">Start</a>") {
+		t.Fatalf("index.html = %q, want the edited embedded nav", out)
+	}
+	if got := deployModTime(t, "about.html"); !got.Equal(past) {
+		t.Fatalf("about.html mtime = %v, want %v (it embeds nothing that changed)", got, past)
+	}
+}
+
+func TestIncrementalRebuildsPageWhenNestedEmbedChanges(t *testing.T) {
+	newTestSite(t, "embed-chained-relative-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+
+	rewriteFuture(t, filepath.Join("partials", "footer.md"), "partial footer", "edited footer")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+
+	if out := readDeploy(t, "index.html"); !strings.Contains(out, "edited footer") {
+		t.Fatalf("index.html = %q, want the edited footer embedded two levels down", out)
+	}
+}
+
+func TestIncrementalRebuildsPagesWhenLayoutEmbedChanges(t *testing.T) {
+	newTestSite(t, "layout-embed-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+
+	rewriteFuture(t, "footer.html", "footer from the layout itself", "edited layout footer")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+
+	for _, page := range []string{"index.html", filepath.Join("section", "index.html")} {
+		if out := readDeploy(t, page); !strings.Contains(out, "edited layout footer") {
+			t.Fatalf("%s = %q, want the edited footer the layout embeds", page, out)
+		}
+	}
+}
+
+func TestManifestRecordsEmbedsAcrossRuns(t *testing.T) {
+	newTestSite(t, "embed-chained-relative-site")
+	ageSources(t, -time.Hour)
+	for run := 1; run <= 2; run++ {
+		if err := generate(t); err != nil {
+			t.Fatalf("generate() run %d error = %v, want nil", run, err)
+		}
+		data, err := os.ReadFile(ManifestFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		entry, ok := m.Outputs["index.html"]
+		if !ok {
+			t.Fatalf("run %d: manifest has no index.html entry: %s", run, data)
+		}
+		// The second run renders nothing, so its entry must have been
+		// carried over from the first, not rebuilt empty.
+		want := []string{"partials/footer.md", "partials/nav.md"}
+		if entry.Source != "index.html" || !slices.Equal(entry.Embeds, want) {
+			t.Fatalf("run %d: index.html entry = %+v, want source index.html, embeds %v", run, entry, want)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package zas
 
 import (
-	"os"
-	"path/filepath"
 	"testing"
 )
 
@@ -86,7 +84,7 @@ func TestSwapExtensionRoundTrip(t *testing.T) {
 // A case-varied extension deliberately does not round-trip: swapExtension
 // always produces to's own casing, so PAGE.MD's deploy path is PAGE.html,
 // not PAGE.HTML, and swapping that back yields PAGE.md, not the original
-// PAGE.MD. This gives every consumer (walk, sourceIsNewer, reaper,
+// PAGE.MD. This gives every consumer (walk, sourceIsNewer,
 // NewZasData) one single, predictable extension casing to agree on,
 // instead of each needing to separately preserve or normalize whatever
 // casing the source file happened to use.
@@ -95,45 +93,3 @@ func TestSwapExtensionNormalizesCase(t *testing.T) {
 		t.Fatalf("swapExtension(%q, ...) = %q, want %q", "PAGE.MD", got, want)
 	}
 }
-
-// reaper reconstructs a candidate source path from a deploy path via
-// swapExtension, which - per TestSwapExtensionNormalizesCase above - always
-// normalizes the guessed extension's own casing. Since a real source's
-// casing might not be that guess (e.g. PAGE.MD's guessed source is
-// "page.md", not "PAGE.MD"), reaper needs a case-insensitive existence
-// check, not a plain os.Open on the exact guessed path.
-func TestGeneratorExistsFold(t *testing.T) {
-	dir := t.TempDir()
-	if err := os.WriteFile(filepath.Join(dir, "PAGE.MD"), nil, 0o644); err != nil {
-		t.Fatal(err)
-	}
-	gen := &Generator{}
-	if !gen.existsFold(filepath.Join(dir, "page.md")) {
-		t.Fatalf("existsFold(%q) = false, want true (PAGE.MD exists, compared case-insensitively)", filepath.Join(dir, "page.md"))
-	}
-	if gen.existsFold(filepath.Join(dir, "other.md")) {
-		t.Fatal("existsFold(...) = true for a file that doesn't exist, want false")
-	}
-}
-
-// existsFold caches each directory's entries in dirEntriesFoldCache after
-// its first read, rather than re-reading and re-scanning the same
-// directory listing on every call - otherwise a directory with many files
-// pays for one full directory read per file instead of one total. This
-// pins the caching itself: a file created after the first existsFold call
-// for its directory must not suddenly become visible within the same
-// Generator, since the whole point is that the directory is read only
-// once per reap walk.
-func TestGeneratorExistsFoldCachesDirectoryListing(t *testing.T) {
-	dir := t.TempDir()
-	gen := &Generator{}
-	if gen.existsFold(filepath.Join(dir, "page.md")) {
-		t.Fatal("existsFold(...) = true before the file was ever created")
-	}
-	if err := os.WriteFile(filepath.Join(dir, "page.md"), nil, 0o644); err != nil {
-		t.Fatal(err)
-	}
-	if gen.existsFold(filepath.Join(dir, "page.md")) {
-		t.Fatal("existsFold(...) = true after the file appeared, want the cached (pre-creation) listing still in effect")
-	}
-}
@@ -0,0 +1,513 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	thtml "html/template"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/atom"
+)
+
+// Feed formats, chosen with a feed's "format" key. Without one, an output
+// ending in ".json" is a JSON Feed and anything else RSS.
+const (
+	feedRSS  = "rss"
+	feedAtom = "atom"
+	feedJSON = "json"
+)
+
+// defaultFeedItems is how many pages a feed lists without an "items" key.
+const defaultFeedItems = 10
+
+// feedConfig is one feed declared in a "feeds" list, in the site config
+// or in a DirConfigFile:
+//
+//	feeds:
+//	  - source: blog
+//	    output: blog/feed.xml
+//	    format: rss
+//	    items: 20
+//	    title: The blog
+//	    description: Everything we write.
+//
+// source and output are relative to the site root for the site config,
+// and to the DirConfigFile's own directory for one declared there, where
+// source defaults to that directory itself.
+type feedConfig struct {
+	// source is the slash-separated directory whose pages the feed lists,
+	// "" for the site root; output is the slash-separated deploy path.
+	source      string
+	output      string
+	format      string
+	items       int
+	title       string
+	description string
+	// declaredIn is the config file the feed comes from.
+	declaredIn string
+}
+
+// feedsKey is the list of feeds declared in ConfigFile or a
+// DirConfigFile.
+const feedsKey = "feeds"
+
+// parseFeeds reads the "feeds" list from config, declared in file, whose
+// paths are relative to dir.
+func parseFeeds(config ConfigSection, dir, file string) ([]*feedConfig, error) {
+	raw, ok := config[feedsKey]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: feeds must be a list, got %T", file, raw)
+	}
+	var feeds []*feedConfig
+	for i, item := range list {
+		var section ConfigSection
+		switch item := item.(type) {
+		case ConfigSection:
+			section = item
+		case map[string]interface{}:
+			section = ConfigSection(item)
+		default:
+			return nil, fmt.Errorf("%s: feeds[%d] must be a map, got %T", file, i, item)
+		}
+		feed := &feedConfig{
+			format:      section.GetString("format"),
+			items:       defaultFeedItems,
+			title:       section.GetString("title"),
+			description: section.GetString("description"),
+			declaredIn:  file,
+		}
+		source := section.GetString("source")
+		output := section.GetString("output")
+		if feed.source = path.Join(filepath.ToSlash(dir), source); feed.source == "." {
+			feed.source = ""
+		} else if !filepath.IsLocal(filepath.FromSlash(feed.source)) {
+			return nil, fmt.Errorf("%s: feeds[%d]: source %q must be inside the site", file, i, source)
+		}
+		if feed.format == "" {
+			feed.format = feedRSS
+			if strings.HasSuffix(output, ".json") {
+				feed.format = feedJSON
+			}
+		}
+		if output == "" {
+			output = map[string]string{feedRSS: "feed.xml", feedAtom: "atom.xml", feedJSON: "feed.json"}[feed.format]
+		}
+		switch feed.format {
+		case feedRSS, feedAtom, feedJSON:
+		default:
+			return nil, fmt.Errorf("%s: feeds[%d]: unknown format %q (want %q, %q or %q)", file, i, feed.format, feedRSS, feedAtom, feedJSON)
+		}
+		feed.output = path.Join(filepath.ToSlash(dir), output)
+		if !filepath.IsLocal(filepath.FromSlash(feed.output)) {
+			return nil, fmt.Errorf("%s: feeds[%d]: output %q must be inside the site", file, i, output)
+		}
+		if items, ok := section["items"].(int); ok && items > 0 {
+			feed.items = items
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+// loadFeeds collects every feed declared in the site config and in the
+// DirConfigFiles discoverPages came across, and marks the pages they'll
+// list, so render keeps their bodies for writeFeeds.
+func (gen *Generator) loadFeeds(dirs []string) error {
+	feeds, err := parseFeeds(gen.Config, ".", ConfigFile)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		config, _, _ := gen.loadZasDirectoryConfig(filepath.Join(dir, DirConfigFile))
+		dirFeeds, err := parseFeeds(config, dir, filepath.Join(dir, DirConfigFile))
+		if err != nil {
+			return err
+		}
+		feeds = append(feeds, dirFeeds...)
+	}
+	gen.feeds = feeds
+	gen.feedPages = make(map[string]bool)
+	for _, feed := range feeds {
+		for _, page := range gen.feedItems(feed) {
+			gen.feedPages[page.Path] = true
+		}
+	}
+	return nil
+}
+
+// feedItems returns the pages feed lists: those below its source, but the
+// source's own index.html, newest first.
+func (gen *Generator) feedItems(feed *feedConfig) PageList {
+	prefix := "/"
+	if feed.source != "" {
+		prefix += feed.source + "/"
+	}
+	var items PageList
+	for _, page := range gen.index.pages {
+		if strings.HasPrefix(page.Path, prefix) && !page.isIndexOf(prefix) {
+			items = append(items, page)
+		}
+	}
+	return items.sorted(func(a, b *PageInfo) int { return b.feedDate().Compare(a.feedDate()) }).Limit(feed.items)
+}
+
+// feedDate is the date a feed lists page under: its "date", or its
+// source's mtime for a page without one.
+func (page *PageInfo) feedDate() time.Time {
+	if page.Date.IsZero() {
+		return page.modTime
+	}
+	return page.Date
+}
+
+// feedBody returns page's body as feeds carry it: the one its manifest
+// entry records (see manifestEntry.Feed), whether render wrote the page
+// this run or walk kept the entry of a page still fresh. Only a page
+// without one - last built before a feed listed it - is built again.
+func (gen *Generator) feedBody(page *PageInfo) (thtml.HTML, error) {
+	var entry *manifestEntry
+	gen.manifestMu.Lock()
+	if gen.manifest != nil {
+		entry = gen.manifest.Outputs[outputOf(page.Path)]
+	}
+	gen.manifestMu.Unlock()
+	if entry != nil && entry.Feed != "" {
+		return thtml.HTML(entry.Feed), nil
+	}
+	input, err := gen.readPage(page.source)
+	if err != nil {
+		return "", err
+	}
+	data, err := gen.buildPage(page.source, input, 1)
+	if err != nil {
+		return "", err
+	}
+	return data.feedBody, nil
+}
+
+// absoluteURLs resolves every relative href and src in body against base,
+// the URL of the page body is from: a feed reader shows it away from the
+// site, where a relative one leads nowhere. body is returned as is if
+// base isn't absolute - a site without a baseurl - or there's nothing to
+// resolve.
+func absoluteURLs(body thtml.HTML, base string) thtml.HTML {
+	baseURL, err := url.Parse(base)
+	if err != nil || !baseURL.IsAbs() {
+		return body
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		return body
+	}
+	resolved := false
+	for _, attr := range []string{atom.Href.String(), atom.Src.String()} {
+		doc.Find("[" + attr + "]").Each(func(_ int, e *goquery.Selection) {
+			ref, err := url.Parse(e.AttrOr(attr, ""))
+			if err != nil || ref.IsAbs() {
+				return
+			}
+			e.SetAttr(attr, baseURL.ResolveReference(ref).String())
+			resolved = true
+		})
+	}
+	if !resolved {
+		return body
+	}
+	html, err := doc.Find(atom.Body.String()).Html()
+	if err != nil {
+		return body
+	}
+	return thtml.HTML(html)
+}
+
+// feedItem is one feed entry, whatever the format.
+type feedItem struct {
+	title   string
+	url     string
+	date    time.Time
+	summary string
+	body    thtml.HTML
+}
+
+// writeFeeds writes every declared feed.
+func (gen *Generator) writeFeeds() error {
+	var errs []error
+	for _, feed := range gen.feeds {
+		if err := gen.writeFeed(feed); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", feed.declaredIn, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (gen *Generator) writeFeed(feed *feedConfig) error {
+	var items []feedItem
+	for _, page := range gen.feedItems(feed) {
+		body, err := gen.feedBody(page)
+		if err != nil {
+			return fmt.Errorf("%s: %w", page.source, err)
+		}
+		body = absoluteURLs(body, page.URL)
+		summary, _ := page.Page["summary"].(string)
+		if summary == "" {
+			summary, _ = page.Page["description"].(string)
+		}
+		if summary == "" {
+			summary = firstParagraph(body)
+		}
+		items = append(items, feedItem{title: page.Title, url: page.URL, date: page.feedDate(), summary: summary, body: body})
+	}
+	baseURL := strings.TrimRight(gen.Config.GetSection("site").GetString("baseurl"), "/")
+	link := baseURL + "/"
+	if feed.source != "" {
+		link += feed.source + "/"
+	}
+	title := feed.title
+	if title == "" {
+		for _, page := range gen.index.pages {
+			if page.isIndexOf(strings.TrimPrefix(link, baseURL)) {
+				title = page.Title
+			}
+		}
+	}
+	if title == "" {
+		title = link
+	}
+	var updated time.Time
+	for _, item := range items {
+		if item.date.After(updated) {
+			updated = item.date
+		}
+	}
+	var (
+		content []byte
+		err     error
+	)
+	selfURL := baseURL + "/" + feed.output
+	switch feed.format {
+	case feedRSS:
+		content, err = rssFeed(title, link, selfURL, feed.description, updated, items)
+	case feedAtom:
+		content, err = atomFeed(title, link, selfURL, updated, items)
+	case feedJSON:
+		content, err = jsonFeed(title, link, selfURL, feed.description, items)
+	}
+	if err != nil {
+		return err
+	}
+	return gen.writeGenerated(feed.output, feed.declaredIn, ruleFeed, content)
+}
+
+// firstParagraph returns the text of body's first paragraph, a feed item's
+// summary when its page config has none.
+func firstParagraph(body thtml.HTML) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(doc.Find("p").First().Text())
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description,omitempty"`
+	Content     xmlCDATA `xml:"content:encoded"`
+}
+
+type xmlCDATA struct {
+	Text string `xml:",cdata"`
+}
+
+func rssFeed(title, link, self, description string, updated time.Time, items []feedItem) ([]byte, error) {
+	doc := rssDocument{
+		Version: "2.0",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        link,
+			Description: description,
+			Self:        atomLink{Rel: "self", Href: self, Type: "application/rss+xml"},
+		},
+	}
+	if !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	for _, item := range items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.title,
+			Link:        item.url,
+			GUID:        item.url,
+			PubDate:     item.date.Format(time.RFC1123Z),
+			Description: item.summary,
+			Content:     xmlCDATA{string(item.body)},
+		})
+	}
+	return marshalXML(doc)
+}
+
+type atomDocument struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Summary   string      `xml:"summary,omitempty"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+func atomFeed(title, link, self string, updated time.Time, items []feedItem) ([]byte, error) {
+	doc := atomDocument{
+		XMLNS:   "http://www.w3.org/2005/Atom",
+		Title:   title,
+		ID:      link,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Href: self, Type: "application/atom+xml"},
+			{Rel: "alternate", Href: link, Type: "text/html"},
+		},
+	}
+	for _, item := range items {
+		date := item.date.Format(time.RFC3339)
+		doc.Entries = append(doc.Entries, atomEntry{
+			Title:     item.title,
+			ID:        item.url,
+			Link:      atomLink{Rel: "alternate", Href: item.url, Type: "text/html"},
+			Published: date,
+			Updated:   date,
+			Summary:   item.summary,
+			Content:   atomContent{Type: "html", Text: string(item.body)},
+		})
+	}
+	return marshalXML(doc)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+type jsonFeedDocument struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html"`
+	Summary       string `json:"summary,omitempty"`
+	DatePublished string `json:"date_published"`
+}
+
+func jsonFeed(title, link, self, description string, items []feedItem) ([]byte, error) {
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       title,
+		HomePageURL: link,
+		FeedURL:     self,
+		Description: description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range items {
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            item.url,
+			URL:           item.url,
+			Title:         item.title,
+			ContentHTML:   string(item.body),
+			Summary:       item.summary,
+			DatePublished: item.date.Format(time.RFC3339),
+		})
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRSS struct {
+	Channel struct {
+		Title       string `xml:"title"`
+		Description string `xml:"description"`
+		Items       []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func readRSS(t *testing.T) testRSS {
+	t.Helper()
+	var feed testRSS
+	if err := xml.Unmarshal([]byte(readDeploy(t, filepath.Join("blog", "feed.xml"))), &feed); err != nil {
+		t.Fatal(err)
+	}
+	return feed
+}
+
+func TestRSSFeedFromDirectoryConfig(t *testing.T) {
+	newTestSite(t, "feed-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	feed := readRSS(t)
+	if feed.Channel.Title != "The blog" || feed.Channel.Description != "Posts from the blog." {
+		t.Fatalf("channel = %q/%q, want the section's title and the configured description", feed.Channel.Title, feed.Channel.Description)
+	}
+	var titles []string
+	for _, item := range feed.Channel.Items {
+		titles = append(titles, item.Title)
+	}
+	if want := []string{"Third post", "Second post"}; !slices.Equal(titles, want) {
+		t.Fatalf("items = %v, want %v (newest first, limited to 2)", titles, want)
+	}
+	second := feed.Channel.Items[1]
+	if second.Link != "http://example.com/blog/second.html" || second.Description != "Second summary" {
+		t.Fatalf("second item = %+v, want its URL and configured summary", second)
+	}
+	if !strings.Contains(second.Content, "<p>Second body.</p>") || strings.Contains(second.Content, "chrome") {
+		t.Fatalf("second item content = %q, want the rendered body without the layout", second.Content)
+	}
+}
+
+func TestJSONAndAtomFeeds(t *testing.T) {
+	newTestSite(t, "feed-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	var feed struct {
+		Version string `json:"version"`
+		FeedURL string `json:"feed_url"`
+		Items   []struct {
+			Title   string `json:"title"`
+			Summary string `json:"summary"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(readDeploy(t, filepath.Join("blog", "feed.json"))), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.Version != "https://jsonfeed.org/version/1.1" || feed.FeedURL != "http://example.com/blog/feed.json" || len(feed.Items) != 3 {
+		t.Fatalf("feed.json = %+v, want a JSON Feed listing all three posts", feed)
+	}
+	if first := feed.Items[2]; first.Title != "First post" || first.Summary != "Hello from the first post." {
+		t.Fatalf("oldest item = %+v, want its first paragraph as summary", first)
+	}
+	atom := readDeploy(t, filepath.Join("blog", "atom.xml"))
+	for _, want := range []string{`<feed xmlns="http://www.w3.org/2005/Atom">`, "<updated>2024-03-10T00:00:00Z</updated>", `<content type="html">&lt;h1 id=&#34;third-post&#34;&gt;Third post&lt;/h1&gt;`} {
+		if !strings.Contains(atom, want) {
+			t.Fatalf("atom.xml = %s, want %s", atom, want)
+		}
+	}
+}
+
+func TestFeedListsPagesNotRenderedThisRun(t *testing.T) {
+	newTestSite(t, "feed-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	rewriteFuture(t, filepath.Join("blog", "third.md"), "Third body.", "Edited third body.")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	items := readRSS(t).Channel.Items
+	if len(items) != 2 || !strings.Contains(items[0].Content, "Edited third body.") || !strings.Contains(items[1].Content, "Second body.") {
+		t.Fatalf("items = %+v, want the edited post and the untouched one", items)
+	}
+	if entry := readManifest(t).Outputs["blog/feed.xml"]; entry == nil || entry.Rule != ruleFeed || entry.Source != "blog/.zas.yml" {
+		t.Fatalf("blog/feed.xml entry = %+v, want a feed declared in blog/.zas.yml", entry)
+	}
+}
+
+func TestFeedBodiesLeaveOutHeadingAnchors(t *testing.T) {
+	newTestSite(t, "feed-site")
+	ageSources(t, -time.Hour)
+	config := filepath.Join("blog", DirConfigFile)
+	data, err := os.ReadFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, append([]byte("heading_anchors: true\n"), data...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, filepath.Join("blog", "third.html")); !strings.Contains(out, `class="anchor"`) {
+		t.Fatalf("blog/third.html = %q, want its heading permalinks", out)
+	}
+	assertNoAnchors := func(run string) {
+		t.Helper()
+		for _, item := range readRSS(t).Channel.Items {
+			if strings.Contains(item.Content, "anchor") {
+				t.Fatalf("%s run: item %q content = %q, want no heading permalinks", run, item.Title, item.Content)
+			}
+		}
+	}
+	assertNoAnchors("first")
+	// The second run builds the untouched post's body for the feed anew.
+	rewriteFuture(t, filepath.Join("blog", "third.md"), "Third body.", "Edited third body.")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertNoAnchors("second")
+}
+
+func TestFeedReusesRecordedBodiesOfFreshPages(t *testing.T) {
+	newTestSite(t, "feed-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	m := readManifest(t)
+	second := m.Outputs["blog/second.html"]
+	if second == nil || !strings.Contains(second.Feed, "<p>Second body.</p>") {
+		t.Fatalf("blog/second.html entry = %+v, want its feed body recorded", second)
+	}
+	// A body only the manifest has shows the fresh page isn't built again.
+	second.Feed = "<p>Recorded body.</p>"
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ManifestFile, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rewriteFuture(t, filepath.Join("blog", "third.md"), "Third body.", "Edited third body.")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	items := readRSS(t).Channel.Items
+	if len(items) != 2 || !strings.Contains(items[0].Content, "Edited third body.") || items[1].Content != "<p>Recorded body.</p>" {
+		t.Fatalf("items = %+v, want the edited post and the recorded body of the untouched one", items)
+	}
+}
+
+func TestFeedBodiesHaveAbsoluteURLs(t *testing.T) {
+	newTestSite(t, "feed-site")
+	rewriteFuture(t, filepath.Join("blog", "second.md"), "Second body.", "Second body, see [the third](third.html) and ![a chart](img/chart.png).")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	content := readRSS(t).Channel.Items[1].Content
+	for _, want := range []string{`href="http://example.com/blog/third.html"`, `src="http://example.com/blog/img/chart.png"`} {
+		if !strings.Contains(content, want) {
+			t.Errorf("second item content = %q, want %s", content, want)
+		}
+	}
+	if out := readDeploy(t, filepath.Join("blog", "second.html")); !strings.Contains(out, `href="third.html"`) {
+		t.Errorf("blog/second.html = %q, want the page's own link left relative", out)
+	}
+}
+
+func TestFeedUnknownFormatFails(t *testing.T) {
+	newTestSite(t, "feed-site")
+	appendConfig(t, "  - source: blog\n    format: gopher\n")
+	if err := generate(t); err == nil || !strings.Contains(err.Error(), "gopher") {
+		t.Fatalf("generate() error = %v, want it to reject the format", err)
+	}
+}
+
+func TestMergedDirConfigDoesNotInheritFeeds(t *testing.T) {
+	newTestSite(t, "feed-site")
+	setZasOption(t, "dirconfig", "merge")
+	if err := os.MkdirAll(filepath.Join("blog", "2024"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join("blog", "2024", DirConfigFile): "title: The 2024 archive\n",
+		filepath.Join("blog", "2024", "fourth.md"):   "# Fourth post\n",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	assertDeployHas(t, filepath.Join("blog", "feed.xml"))
+	assertDeployHas(t, filepath.Join("blog", "feed.json"))
+	assertDeployMissing(t, filepath.Join("blog", "2024", "feed.xml"))
+	assertDeployMissing(t, filepath.Join("blog", "2024", "feed.json"))
+}
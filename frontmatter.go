@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"errors"
+
+	markdown "github.com/yuin/goldmark"
+)
+
+// markdownToHTML converts Markdown input to HTML with converter. Leading
+// YAML front matter, as most other tools write page config:
+//
+//	---
+//	title: Hello
+//	---
+//
+// becomes the leading config comment every page-config reader downstream
+// already looks for (extractPageConfig, earlyPageConfig and
+// pageOptsOutOfTemplating through leadingConfigComment), instead of the
+// <hr> and paragraph CommonMark would make of it. A page with both front
+// matter and a config comment is an error: neither could win without
+// silently dropping the other.
+func markdownToHTML(input []byte, converter markdown.Markdown) ([]byte, error) {
+	matter, body, ok := splitFrontMatter(input)
+	if ok && bytes.Contains(matter, commentClose) {
+		return nil, errors.New("YAML front matter can't contain \"-->\"")
+	}
+	var b bytes.Buffer
+	if ok {
+		b.WriteString(commentOpen + "\n")
+		b.Write(matter)
+		b.Write(commentClose)
+		b.WriteByte('\n')
+	}
+	n := b.Len()
+	if err := converter.Convert(body, &b); err != nil {
+		return nil, err
+	}
+	if _, both := leadingConfigComment(b.Bytes()[n:]); ok && both {
+		return nil, errors.New("page has both YAML front matter and a leading config comment; keep only one")
+	}
+	return b.Bytes(), nil
+}
+
+// splitFrontMatter splits input into its leading front matter - the lines
+// between a first line of "---" and the next line of "---" or "..." - and
+// the rest. Without a closing line there's no front matter, and ok is
+// false.
+func splitFrontMatter(input []byte) (matter, body []byte, ok bool) {
+	first, rest, found := bytes.Cut(input, []byte("\n"))
+	if !found || string(bytes.TrimRight(first, "\r")) != "---" {
+		return nil, input, false
+	}
+	for offset := 0; offset < len(rest); {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		end := min(offset+len(line)+1, len(rest))
+		switch string(bytes.TrimRight(line, "\r")) {
+		case "---", "...":
+			return rest[:offset], rest[end:], true
+		}
+		offset = end
+	}
+	return nil, input, false
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFrontMatterIsPageConfig(t *testing.T) {
+	newTestSite(t, "frontmatter-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, "post.html")
+	for _, want := range []string{"<title>Front matter title</title>", "Titled Front matter title."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("post.html = %q, want %q", out, want)
+		}
+	}
+	if strings.Contains(out, "<hr") {
+		t.Errorf("post.html = %q, want the front matter gone, not a thematic break", out)
+	}
+	if out, want := readDeploy(t, "literal.html"), "Literal {{.Title}} stays."; !strings.Contains(out, want) {
+		t.Errorf("literal.html = %q, want %q", out, want)
+	}
+	assertDeployMissing(t, "hidden.html")
+	if out := readDeploy(t, "plain.html"); !strings.Contains(out, "<hr") {
+		t.Errorf("plain.html = %q, want a thematic break away from the first line kept", out)
+	}
+}
+
+func TestFrontMatterAndConfigCommentFails(t *testing.T) {
+	newTestSite(t, "frontmatter-site")
+	if err := os.WriteFile("both.md", []byte("---\ntitle: One\n---\n<!-- title: Two -->\n# Both\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := generate(t)
+	if err == nil || !strings.Contains(err.Error(), "both YAML front matter and a leading config comment") {
+		t.Fatalf("generate() error = %v, want one about both config styles", err)
+	}
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input, matter, body string
+		ok                  bool
+	}{
+		"dashes":    {"---\na: 1\n---\nbody\n", "a: 1\n", "body\n", true},
+		"dots":      {"---\na: 1\n...\nbody", "a: 1\n", "body", true},
+		"crlf":      {"---\r\na: 1\r\n---\r\nbody", "a: 1\r\n", "body", true},
+		"empty":     {"---\n---\n", "", "", true},
+		"unclosed":  {"---\na: 1\n", "", "---\na: 1\n", false},
+		"not first": {"\n---\na: 1\n---\n", "", "\n---\na: 1\n---\n", false},
+	} {
+		matter, body, ok := splitFrontMatter([]byte(tc.input))
+		if string(matter) != tc.matter || string(body) != tc.body || ok != tc.ok {
+			t.Errorf("%s: splitFrontMatter() = %q, %q, %v, want %q, %q, %v", name, matter, body, ok, tc.matter, tc.body, tc.ok)
+		}
+	}
+}
@@ -40,7 +40,6 @@ import (
 	"github.com/darccio/zas/internal/i18n"
 	markdown "github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/util"
 	yaml "go.yaml.in/yaml/v3"
@@ -93,26 +92,6 @@ func (r *rawHTMLRenderer) renderRawHTML(w util.BufWriter, source []byte, node as
 	return ast.WalkSkipChildren, nil
 }
 
-// markdownConverter passes raw HTML through instead of dropping it - a
-// leading HTML comment (per-page config) and an <embed> tag written
-// directly in a .md file would otherwise never reach Zas. Built once at
-// package init and shared across renderAsync goroutines: goldmark builds a
-// fresh parse context per Convert call, so this is safe for concurrent use.
-//
-// extension.GFM (tables, strikethrough, task lists, autolinks) and
-// extension.Footnote are enabled on top of goldmark's default CommonMark
-// parsing - previously neither was, so e.g. a GFM table rendered as a
-// literal paragraph of pipe characters instead of a <table>. Both are
-// purely additive: they recognize syntax CommonMark alone leaves as plain
-// text, so existing content that doesn't use any of it renders exactly as
-// before.
-var markdownConverter = markdown.New(
-	markdown.WithExtensions(extension.GFM, extension.Footnote),
-	markdown.WithRendererOptions(
-		renderer.WithNodeRenderers(util.Prioritized(&rawHTMLRenderer{}, 100)),
-	),
-)
-
 // NewGenerator returns a Generator ready to Run with the given verbosity,
 // full-generation, and plugin-execution settings.
 func NewGenerator(verbose, full, noPlugins bool) *Generator {
@@ -134,9 +113,10 @@ type Generator struct {
 	Full bool
 	// NoPlugins disables every content-triggered plugin execution: an
 	// <embed> resolving to an external MIME-type plugin
-	// (handleMIMETypePlugin) and a <script type="application/zas+name">
-	// tag that would exec zs<name> (handleScriptPlugin) each fail with a
-	// clear per-page error instead of exec'ing anything. It has no effect
+	// (handleMIMETypePlugin), a <script type="application/zas+name">
+	// tag that would exec zs<name> (handleScriptPlugin) and a fenced code
+	// block mapped to one under codeblocks (handleCodeBlockPlugins) each
+	// fail with a clear per-page error instead of exec'ing anything. It has no effect
 	// on zas's own internal embed handlers (Markdown, Plain, Html), which
 	// never spawn a process, or on the argv dispatch in cmd/zas, which is
 	// chosen by a name the invoking user typed. Note the zs<name> binaries
@@ -146,6 +126,15 @@ type Generator struct {
 	// control (see README's "Plugins" section for the full trust model
 	// this guards).
 	NoPlugins bool
+	// Drafts and Future publish pages that are otherwise left out of
+	// deploy: drafts, with "draft: true" in their config, and pages
+	// scheduled for later, with a "publish_date" (or else "date") still to
+	// come. See publishes.
+	Drafts bool
+	Future bool
+	// now is when Run started, the one instant every scheduled page is
+	// compared against.
+	now time.Time
 	// Config holds the site configuration. Run always overwrites it with
 	// the freshly loaded contents of ConfigFile, so setting it before
 	// calling Run has no effect on Run itself; it's only meaningful when
@@ -154,6 +143,18 @@ type Generator struct {
 	Config ConfigSection
 	// Default layout from Config[Name]["layout"].
 	Layout *thtml.Template
+	// Named layouts from LayoutsDir, parsed the first time a page picks
+	// one (see layoutFor). Guarded by layoutsMu, since pages render
+	// concurrently.
+	layouts   map[string]namedLayout
+	layoutsMu sync.Mutex
+	// Markdown converters for every option set other than the default
+	// one, built the first time a Markdown file needs one (see
+	// markdownFor). Guarded by markdownMu, like layouts.
+	markdownConverters map[markdownOptions]markdown.Markdown
+	markdownMu         sync.Mutex
+	// minify is ConfigFile's minify section, read once by Run.
+	minify minifyOptions
 	// i18n helper.
 	I18n *i18n.Build
 	// layoutModTime, configModTime, and i18nModTime are the shared
@@ -166,6 +167,18 @@ type Generator struct {
 	layoutModTime time.Time
 	configModTime time.Time
 	i18nModTime   time.Time
+	// partials lists PartialsDir's templates, sorted, and partialsModTime
+	// is the newest mtime among them and PartialsDir itself - so adding or
+	// removing a partial counts as a change too. Both are set by
+	// parseLayout, like layoutModTime.
+	partials        []string
+	partialsModTime time.Time
+	// data holds DataDir's decoded files by name, for ZasData.Data, and
+	// dataFiles and dataModTime are to it what partials and
+	// partialsModTime are to PartialsDir. All three are set by loadData.
+	data        map[string]interface{}
+	dataFiles   []string
+	dataModTime time.Time
 	// ZasDirectoryConfigs cache
 	cachedZasDirectoryConfigs map[string]dirConfigEntry
 	// Guards cachedZasDirectoryConfigs, read and written from many renderAsync goroutines.
@@ -200,16 +213,11 @@ type Generator struct {
 	// current reap walk. The reap walk is single-threaded, so no mutex.
 	reapedDirs map[string]struct{}
 
-	// dirEntriesFoldCache caches, per directory, the lowercased basenames
-	// of its entries - built lazily by existsFold on first use and reused
-	// for every other file existsFold is asked about in the same
-	// directory during the current reap walk. Without it, a directory
-	// with many files (case-insensitive extension matching means reaper
-	// can no longer trust a single exact-path os.Open, see existsFold)
-	// would re-read and re-scan the same directory listing once per file
-	// instead of once total. Like reapedDirs, the reap walk that fills
-	// this is single-threaded, so no mutex.
-	dirEntriesFoldCache map[string]map[string]struct{}
+	// liveDirs holds every deploy directory some entry in manifest lives
+	// under, so reaper can keep a directory without scanning the whole
+	// manifest per directory. Built lazily on reaper's first call; like
+	// reapedDirs, only the single-threaded reap walk touches it.
+	liveDirs map[string]struct{}
 
 	// claimedOutputs maps each deploy output path claimed so far to the
 	// source path that claimed it, so two sources that render to the same
@@ -218,6 +226,43 @@ type Generator struct {
 	// touched from walk, whose own invocations are sequential, so no
 	// mutex is needed.
 	claimedOutputs map[string]string
+	// redirects are the page aliases and site redirects loadRedirects
+	// collected, sorted by the URL path they redirect from.
+	redirects []*redirect
+
+	// index is the site-wide page index behind ZasData.Pages, built by
+	// discoverPages before walk starts and only read after.
+	index *pageIndex
+
+	// feeds are the feeds writeFeeds writes once walk is done, and
+	// feedPages the paths of the pages they list, both set by loadFeeds
+	// before walk starts.
+	feeds     []*feedConfig
+	feedPages map[string]bool
+
+	// prevManifest is ManifestFile as the previous run left it (nil on a
+	// -full run, or when there is none yet), loaded before walk starts and
+	// only read after. manifest is the one this run builds, from
+	// renderAsync goroutines and walk alike, hence manifestMu.
+	prevManifest *manifest
+	manifest     *manifest
+	manifestMu   sync.Mutex
+	// prevSources lists prevManifest's outputs by source, for
+	// keepSourceOutputs. Like prevManifest, it's only read once walk
+	// starts.
+	prevSources map[string][]string
+	// pageSources is what discoverPages read from every page's source,
+	// recorded in this run's manifest for the next one to reuse.
+	pageSources map[string]*pageSource
+
+	// depModTimes caches depsChangedSince's stat of each embedded file
+	// and named layout. Like claimedOutputs, only walk touches it.
+	depModTimes map[string]time.Time
+
+	// digests caches fileDigest's hash of each file read for hash
+	// staleness, from walk and renderAsync goroutines alike.
+	digests  map[string]string
+	digestMu sync.Mutex
 }
 
 // renderConcurrency bounds how many renderAsync goroutines may run at
@@ -285,34 +330,6 @@ func swapExtension(path, from, to string) string {
 	return path[:len(path)-len(from)] + to
 }
 
-// existsFold reports whether a file matching name exists in name's
-// directory, compared case-insensitively against the directory's actual
-// entries. reaper reconstructs a candidate source path from a deploy path
-// via swapExtension, which always normalizes to's own casing - so a guess
-// like "./page.md" needs to still find a real "PAGE.MD" source on a
-// case-sensitive filesystem. Each directory's entries are read at most
-// once per reap walk and cached in dirEntriesFoldCache: a directory with
-// many files would otherwise pay for a full re-read and re-scan of the
-// same listing once per file instead of once total.
-func (gen *Generator) existsFold(name string) bool {
-	dir := filepath.Dir(name)
-	names, ok := gen.dirEntriesFoldCache[dir]
-	if !ok {
-		names = map[string]struct{}{}
-		if entries, err := os.ReadDir(dir); err == nil {
-			for _, e := range entries {
-				names[strings.ToLower(e.Name())] = struct{}{}
-			}
-		}
-		if gen.dirEntriesFoldCache == nil {
-			gen.dirEntriesFoldCache = make(map[string]map[string]struct{})
-		}
-		gen.dirEntriesFoldCache[dir] = names
-	}
-	_, found := names[strings.ToLower(filepath.Base(name))]
-	return found
-}
-
 // atomicWriteFile calls write with a temporary file in path's own directory
 // (same filesystem, so the rename below is atomic), then renames it onto
 // path once write fully succeeds. path itself is never opened or truncated,
@@ -364,10 +381,31 @@ func (gen *Generator) atomicWriteFile(path string, write func(io.Writer) error)
 }
 
 // Generate renders and writes the file at data.Path using the given
-// template context.
-func (gen *Generator) Generate(_ string, data *ZasData) (err error) {
+// template context, recording it in the manifest as path's output.
+func (gen *Generator) Generate(path string, data *ZasData) (err error) {
+	layout, layoutFile, err := gen.layoutFor(data)
+	if err != nil {
+		return
+	}
+	if layout == nil {
+		return gen.writeOutput(path, data, layoutFile, func(w io.Writer) error {
+			return gen.writeBody(w, data.Body)
+		})
+	}
+	doc, err := gen.applyLayout(layout, data)
+	if err != nil {
+		return
+	}
+	return gen.writeOutput(path, data, layoutFile, func(w io.Writer) error {
+		return gen.writeHTML(w, doc.Get(0))
+	})
+}
+
+// applyLayout executes layout over data and resolves whatever the layout
+// itself embeds, returning the assembled page.
+func (gen *Generator) applyLayout(layout *thtml.Template, data *ZasData) (doc *goquery.Document, err error) {
 	var processed bytes.Buffer
-	if err = gen.Layout.Execute(&processed, data); err != nil {
+	if err = layout.Execute(&processed, data); err != nil {
 		return
 	}
 	// This parseAndReplace is a second full HTML5 parse of the page - render
@@ -400,7 +438,7 @@ func (gen *Generator) Generate(_ string, data *ZasData) (err error) {
 	// TestGenerateResolvesEmbedInLayoutItself, whose fixture places a
 	// shared footer at the site root, not next to layout.html).
 	data.embedBaseDir = "."
-	doc, err := gen.parseAndReplace(&processed, data, headRendered)
+	doc, err = gen.parseAndReplace(&processed, data, headRendered)
 	if err != nil {
 		return
 	}
@@ -416,9 +454,35 @@ func (gen *Generator) Generate(_ string, data *ZasData) (err error) {
 			}
 		}
 	}
-	return gen.atomicWriteFile(gen.BuildDeployPath(data.Path), func(w io.Writer) error {
-		return html5.Render(w, doc.Get(0))
-	})
+	return doc, nil
+}
+
+// writeOutput writes path's output for data.Path (see outputOf) through
+// write and records it in the manifest, along with the embeds and named
+// layout it was built from.
+func (gen *Generator) writeOutput(path string, data *ZasData, layoutFile string, write func(io.Writer) error) error {
+	output := outputOf(data.Path)
+	var digest *digestWriter
+	if err := gen.atomicWriteFile(gen.BuildDeployPath(filepath.FromSlash(output)), func(w io.Writer) error {
+		digest = newDigestWriter(w)
+		return write(digest)
+	}); err != nil {
+		return err
+	}
+	entry := digest.entry(path)
+	entry.Embeds = data.embeds.sorted()
+	entry.Layout = filepath.ToSlash(layoutFile)
+	if gen.feedPages[data.Path] {
+		entry.Feed = string(data.feedBody)
+	}
+	if data.usesIndex {
+		entry.Index = data.index.key
+	}
+	if gen.hashStaleness() {
+		entry.Key = gen.stalenessKey(path, entry)
+	}
+	gen.recordOutput(output, entry)
+	return nil
 }
 
 // maxEmbedDepth bounds how many levels of <embed> an entry file may nest.
@@ -456,9 +520,18 @@ func (gen *Generator) parseAndReplace(processed io.Reader, data *ZasData, head h
 	if err != nil {
 		return
 	}
+	if err = gen.handleCodeBlockPlugins(doc); err != nil {
+		return
+	}
 	if err = gen.handleScriptTags(doc, head); err != nil {
 		return
 	}
+	// Before embeds are spliced in: each embedded file's links are its own,
+	// rewritten by its own parseAndReplace. The layout's pass only re-reads
+	// what render already rewrote.
+	if head == headDropped {
+		gen.rewriteSourceLinks(doc, data)
+	}
 	err = gen.handleEmbedTags(doc, data)
 	return
 }
@@ -466,6 +539,7 @@ func (gen *Generator) parseAndReplace(processed io.Reader, data *ZasData, head h
 // Run performs a full generation pass over the current directory,
 // rendering every source file into the configured deploy path.
 func (gen *Generator) Run() error {
+	gen.now = time.Now()
 	cfg, err := NewConfig()
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -474,17 +548,36 @@ func (gen *Generator) Run() error {
 		return err
 	}
 	gen.Config = cfg
+	if err = gen.checkStaleness(); err != nil {
+		return err
+	}
+	if err = gen.checkDirConfig(); err != nil {
+		return err
+	}
+	if err = gen.checkMarkdown(); err != nil {
+		return err
+	}
+	if err = gen.loadMinify(); err != nil {
+		return err
+	}
 	if info, statErr := os.Stat(ConfigFile); statErr == nil {
 		gen.configModTime = info.ModTime()
 	}
-	gen.wg.Add(3)
+	if !gen.Full {
+		gen.loadManifest()
+	}
+	gen.wg.Add(4)
 	go gen.parseLayout()
 	go gen.loadI18N()
+	go gen.loadData()
 	go gen.handleDeployPath(gen.Full)
 	gen.wg.Wait()
 	if len(gen.errs) > 0 {
 		return errors.Join(gen.errs...)
 	}
+	if err = gen.discoverPages(); err != nil {
+		return err
+	}
 	// Walking function. It allows to bubble up any error from generator.
 	walk := func(path string, info os.FileInfo, err error) error {
 		return gen.walk(path, info, err)
@@ -494,7 +587,29 @@ func (gen *Generator) Run() error {
 	if walkErr != nil {
 		gen.recordErr(walkErr)
 	}
-	if !gen.Full {
+	if err = gen.writeSitemap(); err != nil {
+		gen.recordErr(err)
+	}
+	if err = gen.writeFeeds(); err != nil {
+		gen.recordErr(err)
+	}
+	if err = gen.writeTaxonomies(); err != nil {
+		gen.recordErr(err)
+	}
+	if err = gen.writeRedirects(); err != nil {
+		gen.recordErr(err)
+	}
+	if walkErr != nil {
+		// The walk stopped short, so this run's manifest lacks whatever it
+		// never reached: keep listing those outputs as the last run did.
+		gen.keepPrevOutputs()
+	}
+	if err = gen.writeManifest(); err != nil {
+		gen.recordErr(err)
+	}
+	// Nor can the reaper tell an output of a deleted source from one the
+	// walk never reached: leave deploy alone until a run walks it all.
+	if !gen.Full && walkErr == nil {
 		// TODO Can we go parallel?
 		// This removes deleted source files in deploy path
 		reapwalk := func(path string, info os.FileInfo, err error) error {
@@ -518,7 +633,11 @@ func (gen *Generator) parseLayout() {
 	if info, statErr := os.Stat(layout); statErr == nil {
 		gen.layoutModTime = info.ModTime()
 	}
-	if gen.Layout, err = thtml.New(filepath.Base(layout)).Funcs(helpers).ParseFiles(layout); err != nil {
+	if err = gen.loadPartials(); err != nil {
+		gen.recordErr(err)
+		return
+	}
+	if gen.Layout, err = gen.parseLayoutFiles(layout); err != nil {
 		gen.recordErr(err)
 	}
 }
@@ -603,6 +722,15 @@ func (gen *Generator) allowedDotDir(path string, info os.FileInfo) bool {
 	return false
 }
 
+// excluded reports whether walk (and discoverPages) must leave path alone:
+// a dot-file or dot-directory not allowlisted by allowedDotDir, anything
+// inside Dir, the deploy path itself, or the layout.
+func (gen *Generator) excluded(path string, info os.FileInfo) bool {
+	dotPath := strings.HasPrefix(filepath.Base(path), ".") && !gen.allowedDotDir(path, info)
+	return dotPath || pathHasComponent(path, Dir) ||
+		path == gen.GetDeployPath() || path == gen.Config.GetZString("layout")
+}
+
 /*
  * Real walking function. Handles all supported files and copy not supported ones in current deployment path.
  */
@@ -622,9 +750,7 @@ func (gen *Generator) walk(path string, info os.FileInfo, err error) (ierr error
 	// allowedDotDir's approval, since nothing beneath a directory that
 	// wasn't pruned ever starts with a dot in its own basename unless it
 	// independently does too.
-	dotPath := strings.HasPrefix(filepath.Base(path), ".") && !gen.allowedDotDir(path, info)
-	if dotPath || pathHasComponent(path, Dir) ||
-		path == gen.GetDeployPath() || path == gen.Config.GetZString("layout") {
+	if gen.excluded(path, info) {
 		if path != "." && info.IsDir() {
 			return filepath.SkipDir
 		}
@@ -651,29 +777,43 @@ func (gen *Generator) walk(path string, info os.FileInfo, err error) (ierr error
 	// creates a source directory's deploy-side counterpart lazily, only
 	// once something actually needs to be written into it, so a directory
 	// whose entire content is skipped never gets an empty one in deploy.
-	if !info.IsDir() && gen.sourceIsNewer(path, info) {
-		outputPath := swapExtension(path, ".md", ".html")
+	if info.IsDir() {
+		return
+	}
+	if !gen.sourceIsNewer(path, info) {
+		// Nothing to render, but this run's manifest must still list the
+		// output, embeds and all, or the next run would forget them.
+		gen.keepSourceOutputs(path)
+		return
+	}
+	outputs := []string{filepath.FromSlash(gen.outputKey(path))}
+	if p := gen.pagination(path); p != nil {
+		outputs = append(outputs, p.extraOutputs()...)
+	}
+	for _, outputPath := range outputs {
 		if claimant, ok := gen.claimedOutputs[outputPath]; ok {
 			gen.recordErr(fmt.Errorf("%s: output path %q already claimed by %s, skipping", path, outputPath, claimant))
 			return
 		}
-		if gen.claimedOutputs == nil {
-			gen.claimedOutputs = make(map[string]string)
-		}
+	}
+	if gen.claimedOutputs == nil {
+		gen.claimedOutputs = make(map[string]string)
+	}
+	for _, outputPath := range outputs {
 		gen.claimedOutputs[outputPath] = path
-		if gen.Verbose {
-			gen.printLine("+", path)
-		}
-		if gen.sem == nil {
-			gen.sem = make(chan struct{}, renderConcurrency())
-		}
-		gen.wg.Add(1)
-		// Blocks once renderConcurrency() goroutines are already in
-		// flight, throttling walk itself until one finishes and releases
-		// its slot - the actual fan-out cap.
-		gen.sem <- struct{}{}
-		go gen.renderAsync(path)
 	}
+	if gen.Verbose {
+		gen.printLine("+", path)
+	}
+	if gen.sem == nil {
+		gen.sem = make(chan struct{}, renderConcurrency())
+	}
+	gen.wg.Add(1)
+	// Blocks once renderConcurrency() goroutines are already in
+	// flight, throttling walk itself until one finishes and releases
+	// its slot - the actual fan-out cap.
+	gen.sem <- struct{}{}
+	go gen.renderAsync(path)
 	return
 }
 
@@ -704,20 +844,25 @@ func (gen *Generator) renderAsync(path string) {
 		gen.mu.Lock()
 		gen.errs = append(gen.errs, fmt.Errorf("%s: %w", path, err))
 		gen.mu.Unlock()
+		gen.keepSourceOutputs(path)
 	}
 }
 
 /*
- * Real reaping function. Reaps all missing source files in current deployment path.
+ * Real reaping function. Reaps everything in the deployment path that this
+ * run's manifest doesn't list: output of a source file that no longer
+ * exists, of a page that newly opted out of standalone publishing via
+ * "publish: false" (see pagePublished), or any other file nothing in the
+ * site produces anymore, such as a temporary file left behind by an
+ * interrupted atomicWriteFile. A directory survives as long as some
+ * manifest entry still lives beneath it.
  *
- * This only reaps deploy output whose source file no longer exists; it does
- * not detect a source file that still exists but newly opted out of
- * standalone publishing via "publish: false" (see pagePublished). That
- * page's previous deploy output is left behind by an incremental run - a
- * -full run, which clears the whole deploy directory upfront, is needed to
- * remove it.
+ * The manifest records each output under the exact path it was written to,
+ * so there's no need to guess a source back from a deploy path - which
+ * used to need swapExtension run in reverse, plus a case-insensitive
+ * directory scan to find e.g. a PAGE.MD source behind PAGE.html.
  */
-func (gen *Generator) reaper(path string, _ os.FileInfo, err error) (ierr error) {
+func (gen *Generator) reaper(path string, info os.FileInfo, err error) (ierr error) {
 	if err != nil {
 		// filepath.Walk lists a directory's children before invoking this
 		// callback for the directory itself, so RemoveAll below leaves Walk
@@ -729,49 +874,61 @@ func (gen *Generator) reaper(path string, _ os.FileInfo, err error) (ierr error)
 		}
 		return err
 	}
-	sourcePath := "." + strings.TrimPrefix(path, gen.GetDeployPath())
-	_, err = os.Stat(sourcePath)
-	if err != nil {
-		reap := true
-		if hasExtension(sourcePath, ".html") {
-			// swapExtension always normalizes to's own casing, so this
-			// guess (e.g. "./page.md") can only ever be lowercase,
-			// regardless of what case the real source used - a plain
-			// os.Stat here would never find a differently-cased source
-			// like "PAGE.MD" on a case-sensitive filesystem. Note this
-			// isn't needed above: an .html-sourced deploy path is never
-			// extension-swapped at all (swapExtension's from is always
-			// ".md"), so it keeps the source's exact original casing, and
-			// plain os.Stat above already matches it correctly.
-			sourcePath = swapExtension(sourcePath, ".html", ".md")
-			if gen.existsFold(sourcePath) {
-				reap = false
-			}
+	rel, err := filepath.Rel(gen.GetDeployPath(), path)
+	if err != nil || rel == "." {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+	if info.IsDir() {
+		if gen.liveDirs == nil {
+			gen.liveDirs = gen.manifestDirs()
 		}
-		if reap {
-			if gen.Verbose {
-				gen.printLine("-", sourcePath)
-			}
-			if rmErr := os.RemoveAll(path); rmErr != nil {
-				gen.recordErr(rmErr)
-			} else {
-				if gen.reapedDirs == nil {
-					gen.reapedDirs = make(map[string]struct{})
-				}
-				gen.reapedDirs[path] = struct{}{}
-			}
+		if _, ok := gen.liveDirs[rel]; ok {
+			return nil
+		}
+	} else if gen.manifest != nil {
+		if _, ok := gen.manifest.Outputs[rel]; ok {
+			return nil
+		}
+	}
+	if gen.Verbose {
+		gen.printLine("-", rel)
+	}
+	if rmErr := os.RemoveAll(path); rmErr != nil {
+		gen.recordErr(rmErr)
+	} else {
+		if gen.reapedDirs == nil {
+			gen.reapedDirs = make(map[string]struct{})
 		}
+		gen.reapedDirs[path] = struct{}{}
 	}
 	return
 }
 
 func (gen *Generator) sourceIsNewer(path string, sourceInfo os.FileInfo) bool {
-	// Shortcut
-	if gen.Full {
+	// Shortcut. Without a previous manifest there's no record of any
+	// output's embeds either, so every output is treated as stale once,
+	// exactly like a -full run minus clearing deploy upfront.
+	if gen.Full || gen.prevManifest == nil {
 		return true
 	}
-	realpath := swapExtension(path, ".md", ".html")
-	destination, err := os.Open(gen.BuildDeployPath(realpath))
+	if gen.indexChanged(gen.outputKey(path)) {
+		return true
+	}
+	// A page this run doesn't publish - a draft, a scheduled one, a
+	// "publish: false" one - isn't in the index. Rendering it anyway is
+	// what leaves an output built by an earlier run with -drafts or
+	// -future out of the manifest, for reaper to remove - unless the last
+	// run did just that already. discoverPages checks the page's date
+	// again every run, so a scheduled page whose time has come is listed,
+	// and stale, as usual.
+	if ruleFor(path) != ruleCopy && !gen.index.lists(path) {
+		return !gen.pageSkipped(path)
+	}
+	if gen.hashStaleness() {
+		return gen.keyChanged(path)
+	}
+	destination, err := os.Open(gen.BuildDeployPath(filepath.FromSlash(gen.outputKey(path))))
 	if err != nil {
 		return true
 	}
@@ -784,62 +941,88 @@ func (gen *Generator) sourceIsNewer(path string, sourceInfo os.FileInfo) bool {
 	if sourceInfo.ModTime().UnixNano() >= destModTime.UnixNano() {
 		return true
 	}
+	// An output the last run didn't record, recorded as coming from
+	// another source (foo.html taking over foo.md's output), or whose size
+	// no longer matches what was recorded (edited or truncated in place,
+	// behind Zas's back) can't be trusted as fresh.
+	entry, ok := gen.prevEntry(gen.outputKey(path))
+	if !ok || entry.Source != filepath.ToSlash(path) || entry.Size != destinationInfo.Size() {
+		return true
+	}
 	// .Before, not UnixNano: a dependency that was never stat'd (e.g. no
 	// i18n.yml, or no .zas.yml anywhere in path's ancestry) leaves its
 	// mtime at time.Time's zero value, and zero.UnixNano() is documented
 	// as undefined for dates this far out - .Before/.After stay well
 	// defined and correctly treat "never stat'd" as "not newer".
-	if !gen.layoutModTime.Before(destModTime) || !gen.configModTime.Before(destModTime) || !gen.i18nModTime.Before(destModTime) {
+	if !gen.layoutModTime.Before(destModTime) || !gen.partialsModTime.Before(destModTime) || !gen.dataModTime.Before(destModTime) || !gen.configModTime.Before(destModTime) || !gen.i18nModTime.Before(destModTime) {
 		return true
 	}
 	_, dirModTime, _ := gen.loadZasDirectoryConfig(path)
-	return !dirModTime.Before(destModTime)
+	if !dirModTime.Before(destModTime) {
+		return true
+	}
+	return gen.depsChangedSince(gen.outputKey(path), destModTime)
 }
 
 /*
  * Renders a Markdown file.
  */
 func (gen *Generator) renderMarkdown(path string) (err error) {
-	input, err := os.ReadFile(path)
+	input, err := gen.readPage(path)
 	if err != nil {
 		return
 	}
-	var b bytes.Buffer
-	if err := markdownConverter.Convert(input, &b); err != nil {
-		return err
-	}
-	// Do not unescape the converter's output here: goldmark escapes code
-	// block contents (and rawHTMLRenderer above already passes raw HTML
-	// through verbatim), so unescaping would let HTML entities inside a
-	// fenced or indented code block turn back into real elements once
-	// parseAndReplace re-parses this as HTML - including a <script> tag
-	// becoming a live, executing script.
-	return gen.render(path, b.Bytes())
+	return gen.render(path, input)
 }
 
 /*
  * Renders a HTML file.
  */
 func (gen *Generator) renderHTML(path string) (err error) {
-	input, err := os.ReadFile(path)
+	input, err := gen.readPage(path)
 	if err != nil {
 		return
 	}
 	return gen.render(path, input)
 }
 
+// readPage reads the page at path as the HTML render expects, converting
+// it first if it's Markdown.
+func (gen *Generator) readPage(path string) ([]byte, error) {
+	input, err := os.ReadFile(path)
+	if err != nil || !hasExtension(path, ".md") {
+		return input, err
+	}
+	// Do not unescape the converter's output here: goldmark escapes code
+	// block contents (and rawHTMLRenderer above already passes raw HTML
+	// through verbatim), so unescaping would let HTML entities inside a
+	// fenced or indented code block turn back into real elements once
+	// parseAndReplace re-parses this as HTML - including a <script> tag
+	// becoming a live, executing script.
+	converter, err := gen.markdownFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return markdownToHTML(input, converter)
+}
+
 // dirConfigEntry is a cached loadZasDirectoryConfig resolution: config is
 // nil when no DirConfigFile exists anywhere in the queried directory's
-// ancestry, and modTime is its zero value in that case too.
+// ancestry, and everything else is its zero value in that case too.
+// cascade is the page config defaults cascading down to the directory (see
+// cascadeKey). files are the DirConfigFiles both were read from, nearest
+// last, for hash staleness, and modTime the newest of their mtimes.
 type dirConfigEntry struct {
 	config  ConfigSection
+	cascade ConfigSection
 	modTime time.Time
+	files   []string
 }
 
 /*
  * Loads DirConfigFile (as defined in constants.go) from current
- * directory or previously found ones, along with that file's own mtime.
- * It must be a YAML file.
+ * directory or previously found ones, along with the newest mtime among
+ * the files it depends on (see inheritDirConfig). It must be a YAML file.
  *
  * Every directory visited while resolving currentpath is cached, not just
  * the one where DirConfigFile was actually found - including a miss
@@ -855,18 +1038,22 @@ func (gen *Generator) loadZasDirectoryConfig(currentpath string) (config ConfigS
 		}
 		return entry.config, entry.modTime, nil
 	}
+	// Whether or not .zas.yml is here, the one in an upper directory
+	// (already cached or not) matters, so we call this recursively.
+	// Unless we are at current working directory.
+	var parent dirConfigEntry
+	if path != "." {
+		_, _, _ = gen.loadZasDirectoryConfig(path)
+		parent, _ = gen.getCachedDirConfig(filepath.Dir(path))
+	}
 	confPath := filepath.Join(path, DirConfigFile)
 	data, err := os.ReadFile(confPath)
 	if err != nil {
-		// Maybe .zas.yml is in an upper directory (already cached or not),
-		// so we call this recursively. Unless we are at current working
-		// directory.
-		var entry dirConfigEntry
-		if path != "." {
-			entry.config, entry.modTime, err = gen.loadZasDirectoryConfig(path)
+		gen.setCachedDirConfig(path, parent)
+		if parent.config == nil {
+			return nil, time.Time{}, os.ErrNotExist
 		}
-		gen.setCachedDirConfig(path, entry)
-		return entry.config, entry.modTime, err
+		return parent.config, parent.modTime, nil
 	}
 	config = make(ConfigSection)
 	if yamlErr := yaml.Unmarshal(data, &config); yamlErr != nil {
@@ -880,11 +1067,17 @@ func (gen *Generator) loadZasDirectoryConfig(currentpath string) (config ConfigS
 		err = fmt.Errorf("%s: %w", confPath, yamlErr)
 		gen.recordErr(err)
 	}
+	entry := dirConfigEntry{config: config, files: []string{confPath}}
 	if info, statErr := os.Stat(confPath); statErr == nil {
-		modTime = info.ModTime()
+		entry.modTime = info.ModTime()
+	}
+	entry, mergeErr := gen.inheritDirConfig(entry, parent)
+	if mergeErr != nil {
+		err = fmt.Errorf("%s: %w", confPath, mergeErr)
+		gen.recordErr(err)
 	}
-	gen.setCachedDirConfig(path, dirConfigEntry{config: config, modTime: modTime})
-	return config, modTime, err
+	gen.setCachedDirConfig(path, entry)
+	return entry.config, entry.modTime, err
 }
 
 /*
@@ -940,6 +1133,16 @@ var commentClose = []byte("-->")
 // at the very start of input - after whitespace/doctype - means there is
 // no leading comment at all.
 func leadingConfigComment(input []byte) ([]byte, bool) {
+	start, end, ok := leadingConfigCommentSpan(input)
+	if !ok {
+		return nil, false
+	}
+	return input[start+len(commentOpen) : end-len(commentClose)], true
+}
+
+// leadingConfigCommentSpan is leadingConfigComment's scan, returning where
+// the comment starts and ends in input, delimiters included.
+func leadingConfigCommentSpan(input []byte) (start, end int, ok bool) {
 	i := skipConfigSpace(input, 0)
 	if hasFoldPrefix(input[i:], doctypePrefix) {
 		end := bytes.IndexByte(input[i:], '>')
@@ -948,20 +1151,35 @@ func leadingConfigComment(input []byte) ([]byte, bool) {
 			// have matched, but input at i still starts with "<!DOCTYPE",
 			// which can never also start with "<!--", so there is no
 			// leading comment either way.
-			return nil, false
+			return 0, 0, false
 		}
 		i += end + 1
 		i = skipConfigSpace(input, i)
 	}
 	if !hasPrefix(input[i:], commentOpen) {
-		return nil, false
+		return 0, 0, false
 	}
-	i += len(commentOpen)
-	end := bytes.Index(input[i:], commentClose)
-	if end < 0 {
-		return nil, false
+	n := bytes.Index(input[i+len(commentOpen):], commentClose)
+	if n < 0 {
+		return 0, 0, false
 	}
-	return input[i : i+end], true
+	return i, i + len(commentOpen) + n + len(commentClose), true
+}
+
+// stripConfigComment returns input without its leading config comment, or
+// the line break right after it, leaving every other byte as it was.
+func stripConfigComment(input []byte) []byte {
+	start, end, ok := leadingConfigCommentSpan(input)
+	if !ok {
+		return input
+	}
+	rest := input[end:]
+	if bytes.HasPrefix(rest, []byte("\r\n")) {
+		rest = rest[2:]
+	} else if bytes.HasPrefix(rest, []byte("\n")) {
+		rest = rest[1:]
+	}
+	return append(input[:start:start], rest...)
 }
 
 // skipConfigSpace advances i past any run of configSpace bytes.
@@ -1107,6 +1325,23 @@ func pageOptsOutOfTemplating(input []byte) bool {
 	return ok && !runTemplate
 }
 
+// verbatimKey is the page config key pageIsVerbatim looks for.
+var verbatimKey = []byte("verbatim")
+
+// pageIsVerbatim reports whether input's leading config comment sets
+// "verbatim: true": the page is deployed as written, short of that
+// comment, with no templating, embeds, plugins or layout, and never
+// minified - for verification files and the like, which must match byte
+// for byte. Like "template: false", it's read from the raw source, before
+// anything else runs (see pageOptsOutOfTemplating).
+func pageIsVerbatim(input []byte) bool {
+	if !bytes.Contains(input, verbatimKey) {
+		return false
+	}
+	verbatim, _ := earlyPageConfig(input)["verbatim"].(bool)
+	return verbatim
+}
+
 // earlyPageConfig extracts a best-effort preview of a page's own Page
 // config map straight from input's raw source bytes, before text/template
 // ever runs - the same raw, pre-execution approach leadingConfigComment
@@ -1199,16 +1434,96 @@ func leadingH1Text(input []byte) (string, bool) {
 // for string(input)'s full-file copy or the parse/execute round trip.
 var templateActionOpen = []byte("{{")
 
+// tocReference is how a page's own template would use {{.TOC}}; without it,
+// buildPage skips parsing the page ahead of its template just to preview
+// the table of contents.
+var tocReference = []byte(".TOC")
+
 /*
  * Generic render function. It expects input to be a valid HTML document.
  * Input can be a valid Go template, unless its leading config comment
  * opts out with "template: false" (see pageOptsOutOfTemplating).
  */
 func (gen *Generator) render(path string, input []byte) (err error) {
+	if pageIsVerbatim(input) {
+		return gen.renderVerbatim(path, input)
+	}
+	data, err := gen.buildPage(path, input, 1)
+	if err != nil {
+		return
+	}
+	gen.skipPage(path, !gen.publishes(data.Page))
+	if !gen.publishes(data.Page) {
+		// This is the answer to upstream issue #15 ("How can we exclude a
+		// file from the generation loop?"): a page opts out of being
+		// written to the deploy directory as its own standalone file with
+		// "publish: false" in its config comment - e.g. a partial that only
+		// ever makes sense embedded into another page via <embed>. The file
+		// is still fully parsed above (so its own template/embeds/page
+		// config all work exactly as before) and stays fully readable and
+		// processable when pulled in elsewhere: Markdown/Plain/Html read
+		// and process the target file directly via os.ReadFile and
+		// resolveEmbedSrc, independent of this decision.
+		//
+		// The flag only lives inside the page's own content, so walk can't
+		// know about it before render parses this far - meaning an
+		// excluded page never has a deploy output for sourceIsNewer to
+		// compare mtimes against. skipPage above records the answer in
+		// ManifestFile instead, so later incremental runs leave the page
+		// alone for as long as its source doesn't change (see
+		// pageSkipped).
+		//
+		// Returning without a Generate call also leaves the page out of
+		// this run's manifest, so reaper removes whatever output it had
+		// published before it opted out.
+		return nil
+	}
+	if err = gen.Generate(path, data); err != nil || data.Paginator == nil {
+		return
+	}
+	// Every further page of a paginated listing is the same source built
+	// again, its template seeing the next page's items.
+	for number := 2; number <= data.Paginator.TotalPages; number++ {
+		if data, err = gen.buildPage(path, input, number); err != nil {
+			return
+		}
+		if err = gen.Generate(path, data); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// renderVerbatim writes the verbatim page at path (see pageIsVerbatim),
+// unless this run doesn't publish it.
+func (gen *Generator) renderVerbatim(path string, input []byte) error {
+	data := NewZasData(path, gen)
+	data.Page = gen.withCascade(path, earlyPageConfig(input))
+	gen.skipPage(path, !gen.publishes(data.Page))
+	if !gen.publishes(data.Page) {
+		return nil
+	}
+	return gen.writeOutput(path, &data, "", func(w io.Writer) error {
+		_, err := w.Write(stripConfigComment(input))
+		return err
+	})
+}
+
+// buildPage runs everything render does short of laying the page out and
+// writing it: templating, embeds, page config, title and body. Feeds call
+// it too, for the bodies of pages this run didn't render. number is the
+// page of a paginated listing to build (see Paginator), and 1 for any
+// other page.
+func (gen *Generator) buildPage(path string, input []byte, number int) (_ *ZasData, err error) {
 	var processed bytes.Buffer
 	// Building context and rendering template.
 	data := NewZasData(path, gen)
 	data.Directory, _, _ = gen.loadZasDirectoryConfig(path)
+	if p := gen.pagination(path); p != nil {
+		data.Paginator = p.paginator(number, data.Site.BaseURL)
+		data.Path = p.pagePath(number)
+		data.usesIndex = true
+	}
 	// The "{{" check goes first, and that order is load-bearing rather
 	// than stylistic: when input has no "{{" at all, templating and the
 	// opt-out branch below produce byte-identical output regardless of
@@ -1245,10 +1560,18 @@ func (gen *Generator) render(path string, input []byte) (err error) {
 		// still run unconditionally afterward and overwrite both with the
 		// real value, so the layout's own view is completely unaffected by
 		// whatever preview the page body saw.
-		data.Page = earlyPageConfig(input)
+		data.Page = gen.withCascade(path, earlyPageConfig(input))
 		if title, ok := leadingH1Text(input); ok {
 			data.FirstTitle = title
 		}
+		// The table of contents gets the same best-effort preview, from
+		// the headings written in input itself: those an embed or the
+		// template would add aren't there yet.
+		if bytes.Contains(input, tocReference) {
+			if preview, previewErr := goquery.NewDocumentFromReader(bytes.NewReader(input)); previewErr == nil {
+				data.TOC = headingIDs(preview)
+			}
+		}
 		// Pass a pointer: text/template only sees pointer-receiver methods
 		// (Title, E, URL, ...) on an addressable value, and a plain "data" here
 		// is not addressable.
@@ -1260,6 +1583,16 @@ func (gen *Generator) render(path string, input []byte) (err error) {
 	if err != nil {
 		return
 	}
+	gen.cleanUnnecessaryPTags(doc)
+	var pageErr error
+	data.Page, pageErr = gen.extractPageConfig(doc)
+	if pageErr != nil {
+		// A malformed page-config comment is reported but doesn't abort the
+		// render: it's kept out of the named err return (unlike every other
+		// error in this function) so the rest of the page still renders.
+		gen.printLine(path, "=>", pageErr)
+	}
+	data.Page = gen.withCascade(path, data.Page)
 	if lost := doc.Find(atom.Head.String()).Children(); lost.Length() > 0 {
 		// HTML5's tree construction sends a handful of elements (script,
 		// meta, link, base, style, title) straight into <head> when they're
@@ -1274,19 +1607,28 @@ func (gen *Generator) render(path string, input []byte) (err error) {
 		lost.Each(func(_ int, e *goquery.Selection) {
 			kinds = append(kinds, goquery.NodeName(e))
 		})
-		return fmt.Errorf("%s: parsed into <head> and would be silently dropped from the page: move it after the page's first real body content (a leading config comment does not count)", strings.Join(kinds, ", "))
-	}
-	gen.cleanUnnecessaryPTags(doc)
-	var pageErr error
-	data.Page, pageErr = gen.extractPageConfig(doc)
-	if pageErr != nil {
-		// A malformed page-config comment is reported but doesn't abort the
-		// render: it's kept out of the named err return (unlike every other
-		// error in this function) so the rest of the page still renders.
-		gen.printLine(path, "=>", pageErr)
+		return nil, fmt.Errorf("%s: parsed into <head> and would be silently dropped from the page: move it after the page's first real body content (a leading config comment does not count)", strings.Join(kinds, ", "))
 	}
 	data.FirstTitle = gen.getTitle(doc)
+	anchors, err := data.ResolveBool("heading_anchors")
+	if err != nil {
+		return
+	}
+	data.TOC = headingIDs(doc)
 	body := doc.Find(atom.Body.String())
+	if anchors && gen.feedPages[data.Path] && body.Size() > 0 {
+		// A feed reader shows each permalink as a stray "#" after its
+		// heading: feeds get the body as it was before them.
+		feedHTML, feedErr := body.Html()
+		if feedErr != nil {
+			err = feedErr
+			return
+		}
+		data.feedBody = thtml.HTML(strings.TrimSpace(feedHTML))
+	}
+	if anchors {
+		headingAnchors(doc)
+	}
 	if body.Size() > 0 {
 		bodyHTML, bodyErr := body.Html()
 		if bodyErr != nil {
@@ -1301,34 +1643,10 @@ func (gen *Generator) render(path string, input []byte) (err error) {
 			}
 		}
 	}
-	if !pagePublished(data.Page) {
-		// This is the answer to upstream issue #15 ("How can we exclude a
-		// file from the generation loop?"): a page opts out of being
-		// written to the deploy directory as its own standalone file with
-		// "publish: false" in its config comment - e.g. a partial that only
-		// ever makes sense embedded into another page via <embed>. The file
-		// is still fully parsed above (so its own template/embeds/page
-		// config all work exactly as before) and stays fully readable and
-		// processable when pulled in elsewhere: Markdown/Plain/Html read
-		// and process the target file directly via os.ReadFile and
-		// resolveEmbedSrc, independent of this decision.
-		//
-		// The flag only lives inside the page's own content, so walk can't
-		// know about it before render parses this far - meaning an
-		// excluded page never has a deploy output for sourceIsNewer to
-		// compare mtimes against, and so is treated as stale (fully
-		// re-parsed, though never written) on every incremental run. This
-		// is a minor inefficiency, not a correctness bug.
-		//
-		// It also means reaper - which only removes deploy output whose
-		// source no longer exists - won't notice a page that keeps
-		// existing but newly opts out with "publish: false": a
-		// previously-published file's stale output under .zas/deploy
-		// survives an incremental run and needs a -full run (which clears
-		// the whole deploy directory upfront) to actually disappear.
-		return nil
+	if data.feedBody == "" {
+		data.feedBody = data.Body
 	}
-	return gen.Generate(path, &data)
+	return &data, nil
 }
 
 // pagePublished reports whether a page should be written to the deploy
@@ -1341,6 +1659,26 @@ func pagePublished(page map[interface{}]interface{}) bool {
 	return !ok || publish
 }
 
+// publishes reports whether this run writes a page to the deploy
+// directory: a published page (see pagePublished), unless it's a draft
+// ("draft: true") and Drafts is off, or scheduled for later and Future is
+// off. A page is scheduled by its "publish_date", or else its "date", in
+// its config; an incremental run publishes it once that time comes, even
+// if its source hasn't changed since (see sourceIsNewer).
+func (gen *Generator) publishes(page map[interface{}]interface{}) bool {
+	if !pagePublished(page) {
+		return false
+	}
+	if draft, _ := page["draft"].(bool); draft && !gen.Drafts {
+		return false
+	}
+	date := configTime(page["publish_date"])
+	if date.IsZero() {
+		date = configTime(page["date"])
+	}
+	return gen.Future || !date.After(gen.now)
+}
+
 /*
  * Removes <p> elements left completely empty by HTML5 parser error
  * recovery: a block element written inline inside a Markdown paragraph
@@ -1418,13 +1756,31 @@ func (gen *Generator) copy(dstPath, srcPath string) (err error) {
 	if err != nil {
 		return err
 	}
+	var digest *digestWriter
 	if err = gen.atomicWriteFile(dstPath, func(w io.Writer) error {
-		_, err := io.Copy(w, src)
+		digest = newDigestWriter(w)
+		if minify := gen.assetMinifier(srcPath); minify != nil {
+			content, err := io.ReadAll(src)
+			if err != nil {
+				return err
+			}
+			_, err = digest.Write(minify(content))
+			return err
+		}
+		_, err := io.Copy(digest, src)
 		return err
 	}); err != nil {
 		return err
 	}
-	return os.Chmod(dstPath, info.Mode())
+	if err = os.Chmod(dstPath, info.Mode()); err != nil {
+		return err
+	}
+	entry := digest.entry(srcPath)
+	if gen.hashStaleness() {
+		entry.Key = gen.stalenessKey(srcPath, entry)
+	}
+	gen.recordOutput(gen.outputKey(srcPath), entry)
+	return nil
 }
 
 // resolveEmbedSrc resolves an <embed src="..."> attribute to an absolute
@@ -1474,22 +1830,27 @@ func (gen *Generator) Markdown(e *goquery.Selection, _ *goquery.Document, data *
 		if err != nil {
 			return err
 		}
+		data.trackEmbed(resolved)
 		mdInput, err := os.ReadFile(resolved)
 		if err != nil {
 			return err
 		}
-		var b bytes.Buffer
-		if err := markdownConverter.Convert(mdInput, &b); err != nil {
+		converter, err := gen.markdownFor(resolved)
+		if err != nil {
+			return err
+		}
+		html, err := markdownToHTML(mdInput, converter)
+		if err != nil {
 			return err
 		}
 		// Any <embed> inside the file just read resolves relative to that
 		// file's own directory, not data's outer page - restored once this
 		// call returns so a sibling embed later in the outer page's own
 		// body still resolves against the outer page again.
-		restore := data.embedBaseDir
-		data.embedBaseDir = filepath.Dir(resolved)
-		mdDoc, err := gen.parseAndReplace(&b, data, headDropped)
-		data.embedBaseDir = restore
+		restore, restoreFile := data.embedBaseDir, data.embedFile
+		data.embedBaseDir, data.embedFile = filepath.Dir(resolved), resolved
+		mdDoc, err := gen.parseAndReplace(bytes.NewReader(html), data, headDropped)
+		data.embedBaseDir, data.embedFile = restore, restoreFile
 		if err != nil {
 			return err
 		}
@@ -1513,6 +1874,7 @@ func (gen *Generator) Plain(e *goquery.Selection, _ *goquery.Document, data *Zas
 		if err != nil {
 			return err
 		}
+		data.trackEmbed(resolved)
 		var input []byte
 		input, err = os.ReadFile(resolved)
 		if err != nil {
@@ -1539,18 +1901,19 @@ func (gen *Generator) Html(e *goquery.Selection, _ *goquery.Document, data *ZasD
 		if err != nil {
 			return err
 		}
+		data.trackEmbed(resolved)
 		var input []byte
 		input, err = os.ReadFile(resolved)
 		if err != nil {
 			return err
 		}
-		// Same save/restore as Markdown above: an <embed> inside the file
-		// just read resolves relative to that file's own directory.
-		restore := data.embedBaseDir
-		data.embedBaseDir = filepath.Dir(resolved)
+		// Same save/restore as Markdown above: an <embed> or a link inside
+		// the file just read resolves relative to that file's own directory.
+		restore, restoreFile := data.embedBaseDir, data.embedFile
+		data.embedBaseDir, data.embedFile = filepath.Dir(resolved), resolved
 		var htmlDoc *goquery.Document
 		htmlDoc, err = gen.parseAndReplace(bytes.NewBuffer(input), data, headDropped)
-		data.embedBaseDir = restore
+		data.embedBaseDir, data.embedFile = restore, restoreFile
 		if err != nil {
 			return err
 		}
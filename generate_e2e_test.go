@@ -46,8 +46,8 @@ func TestGenerateConvertsMarkdown(t *testing.T) {
 		t.Fatalf("generate() error = %v, want nil", err)
 	}
 	out := readDeploy(t, "about.html")
-	if !strings.Contains(out, "<h1>About</h1>") {
-		t.Fatalf("about.html = %q, want it to contain %q", out, "<h1>About</h1>")
+	if !strings.Contains(out, `<h1 id="about">About</h1>`) {
+		t.Fatalf("about.html = %q, want it to contain %q", out, `<h1 id="about">About</h1>`)
 	}
 	if !strings.Contains(out, "This is the about page.") {
 		t.Fatalf("about.html = %q, want it to contain the body text", out)
@@ -62,15 +62,12 @@ func TestGenerateConvertsMarkdown(t *testing.T) {
 //
 // generate(t) here exercises the full pipeline in one call, including the
 // reap phase - which matters, because fixing only the dispatch switch
-// uncovered a second bug: reaper reconstructs a candidate source path from
-// a deploy path via swapExtension, which always normalizes the guessed
-// extension's casing, so its guess for PAGE.html's source was "page.md",
-// not "PAGE.MD". A plain os.Open on that lowercase guess never finds the
-// real, differently-cased source, so reaper wrongly treated PAGE.html as
-// stale and deleted it immediately after render() created it. PAGE.html
-// still being present after generate(t) returns is proof reaper's own
-// existence check (existsFold) is now case-insensitive too, not just the
-// render dispatch.
+// uncovered a second bug: reaper used to reconstruct a candidate source
+// path from a deploy path via swapExtension, whose lowercase guess
+// ("page.md") never found the real PAGE.MD on a case-sensitive
+// filesystem, so PAGE.html was deleted immediately after render() created
+// it. reaper now consults the manifest instead of guessing, and PAGE.html
+// still being present after generate(t) returns pins that.
 func TestGenerateRendersUppercaseMarkdownExtension(t *testing.T) {
 	newTestSite(t, "site")
 	if err := os.WriteFile("PAGE.MD", []byte("# Upper\n"), 0o644); err != nil {
@@ -81,7 +78,7 @@ func TestGenerateRendersUppercaseMarkdownExtension(t *testing.T) {
 	}
 	assertDeployHas(t, "PAGE.html")
 	assertDeployMissing(t, "PAGE.MD")
-	if out := readDeploy(t, "PAGE.html"); !strings.Contains(out, "<h1>Upper</h1>") {
+	if out := readDeploy(t, "PAGE.html"); !strings.Contains(out, `<h1 id="upper">Upper</h1>`) {
 		t.Fatalf("PAGE.html = %q, want it converted from Markdown, not copied verbatim", out)
 	}
 }
@@ -190,3 +190,45 @@ func TestGenerateWalkErrorDrainsInFlightGoroutines(t *testing.T) {
 	assertDeployHas(t, filepath.Join("sect-10-bad", "unreachable.html"))
 	assertDeployHas(t, filepath.Join("sect-15", "page-009.html"))
 }
+
+// TestIncrementalWalkErrorKeepsUnreachedOutputs: an incremental run whose
+// walk stops short must neither reap nor forget the outputs of sources it
+// never reached.
+func TestIncrementalWalkErrorKeepsUnreachedOutputs(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("running as root: directory permission bits don't restrict access, so the injected error can't be triggered")
+	}
+	dir := t.TempDir()
+	copyFixture(t, "walk-error-base", dir)
+	t.Chdir(dir)
+	for _, sub := range []string{"a", "b"} {
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join("a", "x.md"), []byte("# X\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("b", "y.md"), []byte("# Y\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Generator{}).Run(); err != nil {
+		t.Fatalf("first Run() error = %v, want nil", err)
+	}
+
+	if err := os.Chmod("a", 0o000); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(filepath.Join(dir, "a"), 0o755) })
+	if err := (&Generator{}).Run(); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("second Run() error = %v, want the permission error", err)
+	}
+	assertDeployHas(t, filepath.Join("a", "x.html"))
+	assertDeployHas(t, filepath.Join("b", "y.html"))
+	outputs := readManifest(t).Outputs
+	for _, output := range []string{"a/x.html", "b/y.html"} {
+		if outputs[output] == nil {
+			t.Errorf("manifest lacks %s, want it kept from the last run", output)
+		}
+	}
+}
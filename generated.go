@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeGenerated writes content as output, a deploy file Zas builds from
+// the site as a whole (the page index, config) rather than from one source
+// file, and records it in the manifest under rule, as declared in source -
+// the config file asking for it. These are rebuilt on
+// every run, since they're cheap next to rendering, but an unchanged one
+// isn't rewritten, so its mtime keeps meaning something to whatever serves
+// or syncs deploy. A source file that already produced output this run
+// wins: generating over it would silently replace the site's own file.
+func (gen *Generator) writeGenerated(output, source, rule string, content []byte) error {
+	gen.manifestMu.Lock()
+	var claimant *manifestEntry
+	if gen.manifest != nil {
+		claimant = gen.manifest.Outputs[output]
+	}
+	gen.manifestMu.Unlock()
+	if claimant != nil {
+		return fmt.Errorf("%s: not generated, %s already produces it", output, claimant.Source)
+	}
+	digest := newDigestWriter(io.Discard)
+	_, _ = digest.Write(content)
+	entry := digest.entry(source)
+	entry.Rule = rule
+	path := gen.BuildDeployPath(filepath.FromSlash(output))
+	if prev, ok := gen.prevEntry(output); ok && !gen.Full && prev.Hash == entry.Hash {
+		if info, err := os.Stat(path); err == nil && info.Size() == entry.Size {
+			gen.recordOutput(output, entry)
+			return nil
+		}
+	}
+	if err := gen.atomicWriteFile(path, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	}); err != nil {
+		return err
+	}
+	gen.recordOutput(output, entry)
+	return nil
+}
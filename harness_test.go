@@ -4,6 +4,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -113,3 +114,33 @@ func touchFuture(t *testing.T, path string) {
 		t.Fatal(err)
 	}
 }
+
+// appendConfig appends yaml to the site's config.yml, e.g. a whole extra
+// top-level section. It must not repeat a section the file already has.
+func appendConfig(t *testing.T, yaml string) {
+	t.Helper()
+	f, err := os.OpenFile(ConfigFile, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.WriteString(yaml); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setZasOption adds key: value to the site config's zas section.
+func setZasOption(t *testing.T, key, value string) {
+	t.Helper()
+	data, err := os.ReadFile(ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := strings.Replace(string(data), "zas:\n", "zas:\n  "+key+": "+value+"\n", 1)
+	if updated == string(data) {
+		t.Fatal("config.yml has no zas section")
+	}
+	if err := os.WriteFile(ConfigFile, []byte(updated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
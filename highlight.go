@@ -0,0 +1,490 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Highlighted tokens' classes, as HighlightCSS styles them.
+const (
+	hlKeyword  = "hl-keyword"
+	hlString   = "hl-string"
+	hlComment  = "hl-comment"
+	hlNumber   = "hl-number"
+	hlLiteral  = "hl-literal"
+	hlTag      = "hl-tag"
+	hlAttr     = "hl-attr"
+	hlKey      = "hl-key"
+	hlVariable = "hl-variable"
+)
+
+// lexer is how highlight tells one language's tokens apart. It's not a
+// parser: a handful of rules gets common code close enough to what an
+// editor would show, and anything it doesn't recognize stays plain text.
+type lexer struct {
+	// lineComments run to the end of the line, and blockComments from
+	// their opening to their closing delimiter. A "#" only starts one at
+	// a line's start or after whitespace, so "a#b" and "$#" aren't.
+	lineComments  []string
+	blockComments [][2]string
+	// quotes delimit strings, which a backslash escapes in but those in
+	// rawQuotes, and which only those in multiline span lines.
+	// tripleQuotes are Python's """ and ''' strings.
+	quotes, rawQuotes, multiline string
+	tripleQuotes                 bool
+	keywords, literals           []string
+	// variables are the shell's $name and ${name}.
+	variables bool
+	// stringKeys are JSON's: strings followed by ":". lineKeys are
+	// YAML's: whatever starts a line up to a ":".
+	stringKeys, lineKeys bool
+	// markup is HTML, whose tags and attributes highlightMarkup finds
+	// instead of all of the above.
+	markup bool
+}
+
+var (
+	goLexer = &lexer{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+		rawQuotes:     "`",
+		multiline:     "`",
+		keywords:      []string{"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return", "select", "struct", "switch", "type", "var"},
+		literals:      []string{"true", "false", "nil", "iota"},
+	}
+	shellLexer = &lexer{
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		rawQuotes:    "'",
+		multiline:    "\"'",
+		keywords:     []string{"if", "then", "else", "elif", "fi", "for", "while", "until", "do", "done", "case", "esac", "in", "function", "return", "export", "local", "break", "continue"},
+		variables:    true,
+	}
+	yamlLexer = &lexer{
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		rawQuotes:    "'",
+		literals:     []string{"true", "false", "null"},
+		lineKeys:     true,
+	}
+	jsonLexer = &lexer{
+		quotes:     "\"",
+		literals:   []string{"true", "false", "null"},
+		stringKeys: true,
+	}
+	htmlLexer = &lexer{markup: true}
+	jsLexer   = &lexer{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+		multiline:     "`",
+		keywords:      []string{"async", "await", "break", "case", "catch", "class", "const", "continue", "debugger", "default", "delete", "do", "else", "export", "extends", "finally", "for", "from", "function", "if", "import", "in", "instanceof", "let", "new", "of", "return", "static", "super", "switch", "this", "throw", "try", "typeof", "var", "void", "while", "with", "yield"},
+		literals:      []string{"true", "false", "null", "undefined", "NaN", "Infinity"},
+	}
+	pythonLexer = &lexer{
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		tripleQuotes: true,
+		keywords:     []string{"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with", "yield"},
+		literals:     []string{"True", "False", "None"},
+	}
+)
+
+// lexers maps a fence's info string, lowercased, to its language's lexer.
+var lexers = map[string]*lexer{
+	"go":         goLexer,
+	"golang":     goLexer,
+	"sh":         shellLexer,
+	"shell":      shellLexer,
+	"bash":       shellLexer,
+	"zsh":        shellLexer,
+	"console":    shellLexer,
+	"yaml":       yamlLexer,
+	"yml":        yamlLexer,
+	"json":       jsonLexer,
+	"html":       htmlLexer,
+	"xml":        htmlLexer,
+	"js":         jsLexer,
+	"javascript": jsLexer,
+	"py":         pythonLexer,
+	"python":     pythonLexer,
+}
+
+// highlightRenderer renders a fenced code block in a language lexers knows
+// with its tokens wrapped in classed spans, and any other like goldmark's
+// own renderer does: the same <pre><code class="language-..."> either way.
+// Like rawHTMLRenderer, it's registered at a lower priority number than
+// the default HTML renderer, which wins ties.
+type highlightRenderer struct{}
+
+func (r *highlightRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *highlightRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		_, _ = w.WriteString("</code></pre>\n")
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	var code bytes.Buffer
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+	_, _ = w.WriteString("<pre><code")
+	language := n.Language(source)
+	if language != nil {
+		_, _ = w.WriteString(` class="language-`)
+		_, _ = w.Write(util.EscapeHTML(language))
+		_ = w.WriteByte('"')
+	}
+	_ = w.WriteByte('>')
+	if l, ok := lexers[strings.ToLower(string(language))]; ok {
+		_, _ = w.WriteString(highlight(l, code.String()))
+	} else {
+		_, _ = w.Write(util.EscapeHTML(code.Bytes()))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// highlightWriter collects highlighted HTML, escaping text the way
+// goldmark escapes code, and sharing one span between consecutive tokens
+// of the same class.
+type highlightWriter struct {
+	b strings.Builder
+	// class is that of the span still open at the end of b, if any.
+	class string
+}
+
+func (hw *highlightWriter) write(class, text string) {
+	if class != hw.class {
+		if hw.class != "" {
+			hw.b.WriteString("</span>")
+		}
+		if class != "" {
+			hw.b.WriteString(`<span class="` + class + `">`)
+		}
+		hw.class = class
+	}
+	hw.b.Write(util.EscapeHTML([]byte(text)))
+}
+
+func (hw *highlightWriter) String() string {
+	hw.write("", "")
+	return hw.b.String()
+}
+
+// highlight returns code as HTML, its tokens in l wrapped in classed spans.
+//
+// A "{{ ... }}" action is always left plain and whole: a Markdown page is
+// templated after it's converted, and an action split by a span - a Go
+// "if" inside "{{if .Draft}}" - would no longer parse.
+func highlight(l *lexer, code string) string {
+	if l.markup {
+		return highlightMarkup(code)
+	}
+	var hw highlightWriter
+	lineStart := true
+	for i := 0; i < len(code); {
+		n, class := l.token(code, i, lineStart)
+		text := code[i : i+n]
+		hw.write(class, text)
+		switch {
+		case strings.HasSuffix(text, "\n"):
+			lineStart = true
+		case strings.TrimLeft(text, " \t") == "", l.lineKeys && text == "-":
+			// Indentation, or a YAML list item's dash, before a key.
+		default:
+			lineStart = false
+		}
+		i += n
+	}
+	return hw.String()
+}
+
+// yamlKey matches a YAML mapping key, up to the ":" ending it.
+var yamlKey = regexp.MustCompile(`^[^\s#'"{}\[\],:&*!|>%@` + "`" + `-][^\n#]*?:(?:[ \t]|\n|$)`)
+
+// token returns the length of the token at code[i], at least 1, and its
+// class, or "" for plain text. lineStart is whether only whitespace
+// precedes it on its line.
+func (l *lexer) token(code string, i int, lineStart bool) (int, string) {
+	rest := code[i:]
+	c := code[i]
+	if strings.HasPrefix(rest, "{{") {
+		return actionLength(rest), ""
+	}
+	if l.lineKeys && lineStart {
+		if m := yamlKey.FindString(rest); m != "" {
+			return len(strings.TrimRight(m, " \t\n")) - 1, hlKey
+		}
+	}
+	for _, comment := range l.blockComments {
+		if strings.HasPrefix(rest, comment[0]) {
+			return delimitedLength(rest, len(comment[0]), comment[1]), hlComment
+		}
+	}
+	for _, comment := range l.lineComments {
+		if strings.HasPrefix(rest, comment) && (comment != "#" || i == 0 || strings.ContainsRune(" \t\n", rune(code[i-1]))) {
+			if end := strings.IndexByte(rest, '\n'); end >= 0 {
+				return end, hlComment
+			}
+			return len(rest), hlComment
+		}
+	}
+	if l.variables && c == '$' && len(rest) > 1 {
+		if rest[1] == '{' {
+			return delimitedLength(rest, 2, "}"), hlVariable
+		}
+		if strings.IndexByte("#?@*!$-", rest[1]) >= 0 {
+			return 2, hlVariable
+		}
+		if n := identLength(rest[1:]); n > 0 {
+			return 1 + n, hlVariable
+		}
+	}
+	if l.tripleQuotes && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, "'''")) {
+		return delimitedLength(rest, 3, rest[:3]), hlString
+	}
+	if strings.IndexByte(l.quotes, c) >= 0 {
+		n := l.stringLength(rest)
+		if l.stringKeys && strings.HasPrefix(strings.TrimLeft(rest[n:], " \t"), ":") {
+			return n, hlKey
+		}
+		return n, hlString
+	}
+	if isDigit(c) && (i == 0 || !isIdentByte(code[i-1])) {
+		n := 1
+		for n < len(rest) && (isIdentByte(rest[n]) || rest[n] == '.') {
+			n++
+		}
+		return n, hlNumber
+	}
+	if n := identLength(rest); n > 0 {
+		switch word := rest[:n]; {
+		case slices.Contains(l.keywords, word):
+			return n, hlKeyword
+		case slices.Contains(l.literals, word):
+			return n, hlLiteral
+		}
+		return n, ""
+	}
+	return 1, ""
+}
+
+// stringLength returns the length of the string rest starts with, up to
+// and including its closing quote - or, unterminated, up to the end of
+// its line, or of rest for a multiline one.
+func (l *lexer) stringLength(rest string) int {
+	quote := rest[0]
+	raw := strings.IndexByte(l.rawQuotes, quote) >= 0
+	multiline := strings.IndexByte(l.multiline, quote) >= 0
+	for n := 1; n < len(rest); n++ {
+		switch {
+		case rest[n] == '\\' && !raw:
+			n++
+		case rest[n] == quote:
+			return n + 1
+		case rest[n] == '\n' && !multiline:
+			return n
+		}
+	}
+	return len(rest)
+}
+
+// highlightMarkup is highlight for HTML: tag names, attribute names and
+// values, and comments.
+func highlightMarkup(code string) string {
+	var hw highlightWriter
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		switch {
+		case strings.HasPrefix(rest, "{{"):
+			n := actionLength(rest)
+			hw.write("", rest[:n])
+			i += n
+		case strings.HasPrefix(rest, "<!--"):
+			n := delimitedLength(rest, 4, "-->")
+			hw.write(hlComment, rest[:n])
+			i += n
+		case len(rest) > 1 && rest[0] == '<' && (isLetter(rest[1]) || rest[1] == '/' || rest[1] == '!'):
+			i += markupTag(&hw, rest)
+		default:
+			hw.write("", rest[:1])
+			i++
+		}
+	}
+	return hw.String()
+}
+
+// markupTag writes the tag rest starts with, and returns its length.
+func markupTag(hw *highlightWriter, rest string) int {
+	n := 1
+	for n < len(rest) && (rest[n] == '/' || rest[n] == '!') {
+		n++
+	}
+	for n < len(rest) && (isIdentByte(rest[n]) || rest[n] == '-' || rest[n] == ':') {
+		n++
+	}
+	hw.write(hlTag, rest[:n])
+	for n < len(rest) {
+		switch c := rest[n]; {
+		case c == '>':
+			hw.write(hlTag, ">")
+			return n + 1
+		case strings.HasPrefix(rest[n:], "/>"):
+			hw.write(hlTag, "/>")
+			return n + 2
+		case strings.HasPrefix(rest[n:], "{{"):
+			m := actionLength(rest[n:])
+			hw.write("", rest[n:n+m])
+			n += m
+		case c == '"' || c == '\'':
+			m := delimitedLength(rest[n:], 1, string(c))
+			hw.write(hlString, rest[n:n+m])
+			n += m
+		case c == '=' || c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			hw.write("", rest[n:n+1])
+			n++
+		default:
+			m := n
+			for m < len(rest) && strings.IndexByte(" \t\r\n=>\"'", rest[m]) < 0 && !strings.HasPrefix(rest[m:], "/>") && !strings.HasPrefix(rest[m:], "{{") {
+				m++
+			}
+			class := hlAttr
+			if rest[n-1] == '=' {
+				class = hlString
+			}
+			hw.write(class, rest[n:m])
+			n = m
+		}
+	}
+	return n
+}
+
+// actionLength returns the length of the template action rest starts
+// with, "}}" included, or all of rest if it's never closed.
+func actionLength(rest string) int {
+	return delimitedLength(rest, 2, "}}")
+}
+
+// delimitedLength returns the length of rest up to and including the
+// first close after its opening open bytes, or all of rest without one.
+func delimitedLength(rest string, open int, close string) int {
+	if end := strings.Index(rest[open:], close); end >= 0 {
+		return open + end + len(close)
+	}
+	return len(rest)
+}
+
+// identLength returns the length of the identifier s starts with, or 0.
+func identLength(s string) int {
+	if s == "" || !(isLetter(s[0]) || s[0] == '_') {
+		return 0
+	}
+	n := 1
+	for n < len(s) && isIdentByte(s[n]) {
+		n++
+	}
+	return n
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '_'
+}
+
+// highlightThemes are the CSS themes HighlightCSS writes, by name.
+var highlightThemes = map[string]map[string]string{
+	"light": {
+		hlKeyword:  "color: #d73a49",
+		hlString:   "color: #032f62",
+		hlComment:  "color: #6a737d; font-style: italic",
+		hlNumber:   "color: #005cc5",
+		hlLiteral:  "color: #005cc5",
+		hlTag:      "color: #22863a",
+		hlAttr:     "color: #6f42c1",
+		hlKey:      "color: #005cc5",
+		hlVariable: "color: #e36209",
+	},
+	"dark": {
+		hlKeyword:  "color: #c678dd",
+		hlString:   "color: #98c379",
+		hlComment:  "color: #7f848e; font-style: italic",
+		hlNumber:   "color: #d19a66",
+		hlLiteral:  "color: #d19a66",
+		hlTag:      "color: #e06c75",
+		hlAttr:     "color: #e5c07b",
+		hlKey:      "color: #61afef",
+		hlVariable: "color: #e06c75",
+	},
+}
+
+// DefaultHighlightTheme is the theme HighlightCSS writes unless told
+// otherwise.
+const DefaultHighlightTheme = "light"
+
+// HighlightThemes returns the names of the themes HighlightCSS knows,
+// sorted.
+func HighlightThemes() []string {
+	names := make([]string, 0, len(highlightThemes))
+	for name := range highlightThemes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// HighlightCSS returns the named theme's stylesheet for highlighted code
+// blocks' classes.
+func HighlightCSS(theme string) ([]byte, error) {
+	styles, ok := highlightThemes[theme]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight theme %q (want one of %s)", theme, strings.Join(HighlightThemes(), ", "))
+	}
+	classes := make([]string, 0, len(styles))
+	for class := range styles {
+		classes = append(classes, class)
+	}
+	slices.Sort(classes)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "/* %s syntax highlighting, %s theme */\n", Name, theme)
+	for _, class := range classes {
+		fmt.Fprintf(&b, ".%s { %s; }\n", class, styles[class])
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFencedCodeBlocksAreHighlighted(t *testing.T) {
+	newTestSite(t, "highlight-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, "page.html")
+	for _, want := range []string{
+		`<pre><code class="language-go"><span class="hl-comment">// Greet says hi.</span>`,
+		// Template actions in code still run, highlighted or not.
+		`<span class="hl-keyword">return</span> <span class="hl-string">&#34;hi&#34;</span>`,
+		`<span class="hl-keyword">var</span> shown = <span class="hl-number">1</span>`,
+		`<pre><code class="language-ruby">puts &#34;not highlighted&#34;`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("page.html = %q, want %q", out, want)
+		}
+	}
+	if out, want := readDeploy(t, "index.html"), `<span class="hl-keyword">def</span> main():  <span class="hl-comment"># entry point</span>`; !strings.Contains(out, want) {
+		t.Errorf("index.html = %q, want the embedded Markdown's code highlighted: %q", out, want)
+	}
+}
+
+func TestHighlightIsOptIn(t *testing.T) {
+	newTestSite(t, "highlight-site")
+	data, err := os.ReadFile(ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _, _ := strings.Cut(string(data), "markdown:")
+	if err := os.WriteFile(ConfigFile, []byte(before), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, "page.html"); strings.Contains(out, "hl-") {
+		t.Errorf("page.html = %q, want no highlighting without markdown: highlight: true", out)
+	}
+}
+
+func TestHighlightCanBeTurnedOff(t *testing.T) {
+	newTestSite(t, "highlight-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if out, want := readDeploy(t, filepath.Join("plain", "page.html")), `<pre><code class="language-go">func main() {}`; !strings.Contains(out, want) {
+		t.Errorf("plain/page.html = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package zas
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightLanguages(t *testing.T) {
+	for name, tc := range map[string]struct {
+		lang, code, want string
+	}{
+		"go":          {"go", "x := `a\\` // c", `x := <span class="hl-string">` + "`a\\`" + `</span> <span class="hl-comment">// c</span>`},
+		"go block":    {"go", "/* a */ 0x1F", `<span class="hl-comment">/* a */</span> <span class="hl-number">0x1F</span>`},
+		"shell":       {"sh", "echo \"$HOME\" ${X} $# a#b # c", `echo <span class="hl-string">&quot;$HOME&quot;</span> <span class="hl-variable">${X}</span> <span class="hl-variable">$#</span> a#b <span class="hl-comment"># c</span>`},
+		"shell kw":    {"bash", "if true; then fi", `<span class="hl-keyword">if</span> true; <span class="hl-keyword">then</span> <span class="hl-keyword">fi</span>`},
+		"yaml":        {"yaml", "site:\n  - base url: 'x' # c\n  on: true", "<span class=\"hl-key\">site</span>:\n  - <span class=\"hl-key\">base url</span>: <span class=\"hl-string\">'x'</span> <span class=\"hl-comment\"># c</span>\n  <span class=\"hl-key\">on</span>: <span class=\"hl-literal\">true</span>"},
+		"json":        {"json", `{"a": "b", "c": null}`, `{<span class="hl-key">&quot;a&quot;</span>: <span class="hl-string">&quot;b&quot;</span>, <span class="hl-key">&quot;c&quot;</span>: <span class="hl-literal">null</span>}`},
+		"html":        {"html", `<!-- c --><a href="/x" hidden>t</a>`, `<span class="hl-comment">&lt;!-- c --&gt;</span><span class="hl-tag">&lt;a</span> <span class="hl-attr">href</span>=<span class="hl-string">&quot;/x&quot;</span> <span class="hl-attr">hidden</span><span class="hl-tag">&gt;</span>t<span class="hl-tag">&lt;/a&gt;</span>`},
+		"javascript":  {"js", "const s = `${a}`;", `<span class="hl-keyword">const</span> s = <span class="hl-string">` + "`${a}`" + `</span>;`},
+		"python":      {"python", "'''doc''' if x is None:", `<span class="hl-string">'''doc'''</span> <span class="hl-keyword">if</span> x <span class="hl-keyword">is</span> <span class="hl-literal">None</span>:`},
+		"action kept": {"go", "{{if .Draft}}if{{end}}", `{{if .Draft}}<span class="hl-keyword">if</span>{{end}}`},
+	} {
+		l, ok := lexers[tc.lang]
+		if !ok {
+			t.Fatalf("%s: no lexer for %q", name, tc.lang)
+		}
+		if got := highlight(l, tc.code); got != tc.want {
+			t.Errorf("%s: highlight(%q) = %q, want %q", name, tc.code, got, tc.want)
+		}
+	}
+}
+
+func TestHighlightCSS(t *testing.T) {
+	for _, theme := range HighlightThemes() {
+		css, err := HighlightCSS(theme)
+		if err != nil {
+			t.Fatalf("HighlightCSS(%q) error = %v, want nil", theme, err)
+		}
+		for _, class := range []string{hlKeyword, hlString, hlComment, hlNumber, hlLiteral, hlTag, hlAttr, hlKey, hlVariable} {
+			if !strings.Contains(string(css), "."+class+" {") {
+				t.Errorf("HighlightCSS(%q) = %s, want a rule for %s", theme, css, class)
+			}
+		}
+	}
+	if _, err := HighlightCSS("neon"); err == nil {
+		t.Error("HighlightCSS(\"neon\") error = nil, want one about the unknown theme")
+	}
+}
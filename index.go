@@ -0,0 +1,396 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// PageInfo is one page's entry in the site-wide page index, as listed by
+// {{.Pages}} and {{.Section}}. Everything in it comes from the page's raw
+// source, read before any page renders - the same best-effort view a
+// page's own body gets of {{.Page}} and {{.Title}} (see earlyPageConfig and
+// leadingH1Text): a title or config value only a template would produce
+// isn't known yet.
+type PageInfo struct {
+	// Path is the page's URL path, as in ZasData.Path.
+	Path string
+	// Title is the page config's "title", or else its first <h1>'s text.
+	Title string
+	// URL is Path prefixed with the site's base URL.
+	URL string
+	// Page is the page's config comment, and Directory the DirConfigFile
+	// that applies to it.
+	Page      map[interface{}]interface{}
+	Directory ConfigSection
+	// Date and Weight are the page config's "date" and "weight", zero when
+	// unset or unparseable.
+	Date   time.Time
+	Weight int
+	// Language is the page's resolved language, as in ZasData.Language.
+	Language string
+	// source is the page's source file, and modTime its mtime.
+	source  string
+	modTime time.Time
+}
+
+// PageList is a list of pages from the page index. Its sorting methods
+// return sorted copies, so they chain in templates:
+//
+//	{{range ((.Section "blog").ByDate.Reverse.Limit 5)}}...{{end}}
+type PageList []*PageInfo
+
+// ByDate returns the pages sorted by date, oldest first.
+func (pl PageList) ByDate() PageList {
+	return pl.sorted(func(a, b *PageInfo) int { return a.Date.Compare(b.Date) })
+}
+
+// ByWeight returns the pages sorted by weight, lightest first.
+func (pl PageList) ByWeight() PageList {
+	return pl.sorted(func(a, b *PageInfo) int { return cmp.Compare(a.Weight, b.Weight) })
+}
+
+// ByTitle returns the pages sorted by title.
+func (pl PageList) ByTitle() PageList {
+	return pl.sorted(func(a, b *PageInfo) int { return strings.Compare(a.Title, b.Title) })
+}
+
+// Reverse returns the pages in reverse order.
+func (pl PageList) Reverse() PageList {
+	reversed := slices.Clone(pl)
+	slices.Reverse(reversed)
+	return reversed
+}
+
+// Limit returns at most the first n pages.
+func (pl PageList) Limit(n int) PageList {
+	if n < 0 || n >= len(pl) {
+		return pl
+	}
+	return pl[:n:n]
+}
+
+// sorted returns a stably sorted copy of pl, ties keeping the index's own
+// order by path.
+func (pl PageList) sorted(compare func(a, b *PageInfo) int) PageList {
+	sorted := slices.Clone(pl)
+	slices.SortStableFunc(sorted, compare)
+	return sorted
+}
+
+// Pages returns every published page in the site, sorted by path. Using it
+// makes the page depend on the whole index: an incremental run rebuilds it
+// whenever any page's title or config changes, or a page comes or goes.
+func (zd *ZasData) Pages() PageList {
+	if zd.index == nil {
+		return nil
+	}
+	zd.usesIndex = true
+	return zd.index.pages
+}
+
+// Section returns the published pages below the directory name (e.g.
+// "blog", or "docs/guides"), sorted by path. The section's own index.html
+// isn't listed in it, so a section index can list its section.
+func (zd *ZasData) Section(name string) PageList {
+	if zd.index == nil {
+		return nil
+	}
+	zd.usesIndex = true
+	prefix := "/" + strings.Trim(path.Clean("/"+name), "/") + "/"
+	var section PageList
+	for _, page := range zd.index.pages {
+		if strings.HasPrefix(page.Path, prefix) && !page.isIndexOf(prefix) {
+			section = append(section, page)
+		}
+	}
+	return section
+}
+
+// pageIndex is the site-wide page index and the taxonomies and paginated
+// listings built from it, built by discoverPages before any page renders
+// and only read after. key fingerprints it, so a page that used it can
+// tell whether it changed since.
+type pageIndex struct {
+	pages       PageList
+	taxonomies  map[string]*Taxonomy
+	paginations map[string]*pagination
+	sources     map[string]*PageInfo
+	key         string
+}
+
+// lists reports whether the page at source is in the index. Without an
+// index there's nothing to tell, and every page counts as listed.
+func (idx *pageIndex) lists(source string) bool {
+	if idx == nil {
+		return true
+	}
+	_, ok := idx.sources[source]
+	return ok
+}
+
+// page returns the page at source, or nil if it isn't in the index.
+func (idx *pageIndex) page(source string) *PageInfo {
+	if idx == nil {
+		return nil
+	}
+	return idx.sources[source]
+}
+
+// discoverPages builds gen.index from every Markdown and HTML page walk
+// will render, then loads the redirects and feeds they declare.
+func (gen *Generator) discoverPages() error {
+	var (
+		pages      PageList
+		configDirs []string
+	)
+	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// walk reports it, in its own pass.
+			return nil
+		}
+		if gen.excluded(path, info) {
+			if path != "." && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if _, err := os.Stat(filepath.Join(path, DirConfigFile)); err == nil {
+				configDirs = append(configDirs, path)
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || !(hasExtension(path, ".md") || hasExtension(path, ".html")) {
+			return nil
+		}
+		// A page that can't be read here can't render either, and render
+		// reports it; it just isn't listed.
+		if page, err := gen.discoverPage(path); err == nil && page != nil {
+			pages = append(pages, page)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// filepath.Walk's lexical order is the sources', not the outputs' -
+	// the two differ once ".md" becomes ".html".
+	slices.SortFunc(pages, func(a, b *PageInfo) int { return strings.Compare(a.Path, b.Path) })
+	h := sha256.New()
+	for _, page := range pages {
+		_, _ = fmt.Fprintf(h, "%s\x00%s\x00%v\x00%v\n", page.Path, page.Title, page.Page, page.Directory)
+	}
+	taxonomies, err := gen.loadTaxonomies(pages)
+	if err != nil {
+		return err
+	}
+	paginations, err := loadPaginations(pages)
+	if err != nil {
+		return err
+	}
+	sources := make(map[string]*PageInfo, len(pages))
+	for _, page := range pages {
+		sources[page.source] = page
+	}
+	gen.index = &pageIndex{pages: pages, taxonomies: taxonomies, paginations: paginations, sources: sources, key: hex.EncodeToString(h.Sum(nil))}
+	if err = gen.loadRedirects(); err != nil {
+		return err
+	}
+	return gen.loadFeeds(configDirs)
+}
+
+// discoverPage reads the page at path into a PageInfo, or returns nil if
+// this run doesn't publish it (see publishes).
+func (gen *Generator) discoverPage(path string) (*PageInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	source, err := gen.readPageSource(path)
+	if err != nil {
+		return nil, err
+	}
+	config := gen.withCascade(path, source.config())
+	if !gen.publishes(config) {
+		return nil, nil
+	}
+	data := NewZasData(path, gen)
+	data.Page = config
+	data.Directory, _, _ = gen.loadZasDirectoryConfig(path)
+	if gen.prettyURLs(path, config) {
+		data.Path = prettyPath(data.Path)
+	}
+	data.FirstTitle = source.Title
+	page := &PageInfo{
+		Path:      data.Path,
+		Title:     data.Title(),
+		URL:       data.URL(),
+		Page:      config,
+		Directory: data.Directory,
+		source:    path,
+		modTime:   info.ModTime(),
+	}
+	if page.Language, err = data.Language(); err != nil {
+		return nil, err
+	}
+	page.Date = configTime(config["date"])
+	page.Weight, _ = config["weight"].(int)
+	return page, nil
+}
+
+// pageSource is what discoverPage reads from a page's source: its leading
+// config comment and the text of its first <h1>, as render sees them - for
+// Markdown, once converted. ManifestFile keeps it, so an incremental run
+// only converts the Markdown that changed since the last one.
+type pageSource struct {
+	// Hash is the hex-encoded SHA-256 of the source file, and Markdown the
+	// options it was converted with, empty for HTML. A source read with
+	// both the same reuses the rest.
+	Hash     string `json:"hash"`
+	Markdown string `json:"markdown,omitempty"`
+	// Config is the leading config comment's content, and Title the first
+	// <h1>'s text, each empty if there's none.
+	Config string `json:"config,omitempty"`
+	Title  string `json:"title,omitempty"`
+	// Skipped is set once render found the page unpublished (see
+	// publishes): a draft, a scheduled or a "publish: false" page. Kept
+	// along with the rest while the source is unchanged, it spares an
+	// incremental run building the page only to leave it out again.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// config returns the page config in ps.Config, nil if there's none or it
+// isn't valid YAML, as earlyPageConfig does.
+func (ps *pageSource) config() map[interface{}]interface{} {
+	var config map[interface{}]interface{}
+	if err := yaml.Unmarshal([]byte(ps.Config), &config); err != nil {
+		return nil
+	}
+	return config
+}
+
+// readPageSource reads the page at path into a pageSource, reusing the
+// previous run's if path is unchanged since, and records it for this run's
+// manifest.
+func (gen *Generator) readPageSource(path string) (*pageSource, error) {
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(input)
+	source := &pageSource{Hash: hex.EncodeToString(sum[:])}
+	var opts markdownOptions
+	isMarkdown := hasExtension(path, ".md")
+	if isMarkdown {
+		if opts, err = gen.markdownOptionsFor(path); err != nil {
+			return nil, err
+		}
+		source.Markdown = fmt.Sprintf("%+v", opts)
+	}
+	if prev := gen.prevPageSource(path); prev != nil && prev.Hash == source.Hash && prev.Markdown == source.Markdown {
+		source = prev
+	} else {
+		if isMarkdown {
+			if input, err = markdownToHTML(input, gen.converterFor(opts)); err != nil {
+				return nil, err
+			}
+		}
+		if comment, ok := leadingConfigComment(input); ok {
+			source.Config = string(comment)
+		}
+		if title, ok := leadingH1Text(input); ok {
+			source.Title = plainText(title)
+		}
+	}
+	if gen.pageSources == nil {
+		gen.pageSources = make(map[string]*pageSource)
+	}
+	gen.pageSources[filepath.ToSlash(path)] = source
+	return source, nil
+}
+
+// skipPage records whether render left the page at path unpublished.
+// Each source renders in one goroutine only, so no mutex.
+func (gen *Generator) skipPage(path string, skipped bool) {
+	if source := gen.pageSources[filepath.ToSlash(path)]; source != nil {
+		source.Skipped = skipped
+	}
+}
+
+// pageSkipped reports whether the previous run left the page at path
+// unpublished and its source hasn't changed since.
+func (gen *Generator) pageSkipped(path string) bool {
+	source := gen.pageSources[filepath.ToSlash(path)]
+	return source != nil && source.Skipped && source == gen.prevPageSource(path)
+}
+
+// prevPageSource returns the previous run's pageSource for the page at
+// path, or nil if it has none.
+func (gen *Generator) prevPageSource(path string) *pageSource {
+	if gen.prevManifest == nil {
+		return nil
+	}
+	return gen.prevManifest.Pages[filepath.ToSlash(path)]
+}
+
+// configTime returns value, a page config date, as a time.Time: YAML
+// decodes an unquoted timestamp into one already, and a quoted one is
+// parsed here. Anything else is the zero time.
+func configTime(value interface{}) time.Time {
+	switch value := value.(type) {
+	case time.Time:
+		return value
+	case string:
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// plainText returns the text content of the HTML fragment s.
+func plainText(s string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	return strings.TrimSpace(doc.Text())
+}
+
+// indexChanged reports whether output was built from a page index other
+// than this run's.
+func (gen *Generator) indexChanged(output string) bool {
+	entry, ok := gen.prevEntry(output)
+	return ok && entry.Index != "" && entry.Index != gen.index.key
+}
@@ -128,6 +128,13 @@ func (cs ConfigSection) GetStringOK(key string) (value string, ok bool) {
 	return
 }
 
+// GetBoolOK returns a boolean value from current section, and whether key
+// was present and held a boolean value.
+func (cs ConfigSection) GetBoolOK(key string) (value bool, ok bool) {
+	value, ok = cs[key].(bool)
+	return
+}
+
 // GetSection returns a subsection from current section, or nil if key is
 // missing or not a section.
 func (cs ConfigSection) GetSection(key string) (value ConfigSection) {
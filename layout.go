@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"fmt"
+	thtml "html/template"
+	"os"
+	"path/filepath"
+	"text/template/parse"
+)
+
+// layoutNone is the "layout" value that writes a page's body with no
+// layout around it, for fragments. A file that must match byte for byte
+// is a verbatim page instead (see pageIsVerbatim).
+const layoutNone = "none"
+
+// namedLayout is a parsed file from LayoutsDir, or the error parsing it
+// failed with, cached so every page sharing a layout parses it only once.
+type namedLayout struct {
+	tmpl *thtml.Template
+	err  error
+}
+
+// layoutFor picks the layout data's page renders into, from the "layout"
+// key of its page config, then of its directory config - the site-wide
+// default, Layout, applies when neither sets one. file is the chosen
+// layout's path, empty for the default; a nil tmpl with a nil err means
+// layoutNone.
+func (gen *Generator) layoutFor(data *ZasData) (tmpl *thtml.Template, file string, err error) {
+	value, ok := layoutValue(data)
+	if !ok {
+		return gen.Layout, "", nil
+	}
+	name, isString := value.(string)
+	switch {
+	case !isString:
+		return nil, "", fmt.Errorf("config value %q must be a string, got %T", "layout", value)
+	case name == "":
+		return gen.Layout, "", nil
+	case name == layoutNone:
+		return nil, "", nil
+	case !filepath.IsLocal(filepath.FromSlash(name)):
+		return nil, "", fmt.Errorf("layout %q must name a file inside %s", name, LayoutsDir)
+	}
+	file = filepath.Join(LayoutsDir, filepath.FromSlash(name))
+	gen.layoutsMu.Lock()
+	defer gen.layoutsMu.Unlock()
+	layout, ok := gen.layouts[file]
+	if !ok {
+		layout.tmpl, layout.err = gen.parseLayoutFiles(file)
+		if layout.err == nil && isBlocksOnly(layout.tmpl) {
+			// Nothing but {{define}}s: the layout only overrides blocks
+			// of the default one, which it's laid out with instead.
+			layout.tmpl, layout.err = gen.parseLayoutFiles(gen.Config.GetZString("layout"), file)
+		}
+		if gen.layouts == nil {
+			gen.layouts = make(map[string]namedLayout)
+		}
+		gen.layouts[file] = layout
+	}
+	return layout.tmpl, file, layout.err
+}
+
+// layoutValue returns the "layout" key of data's page config, or else of
+// its directory config.
+func layoutValue(data *ZasData) (value interface{}, ok bool) {
+	value, ok = data.Page["layout"]
+	if !ok && data.Directory != nil {
+		value, ok = data.Directory["layout"]
+	}
+	return
+}
+
+// parseLayoutFiles parses the partials and then files into one template
+// set named after files[0], so a layout's own {{define}} overrides a
+// partial's, and a later file's overrides an earlier one's.
+func (gen *Generator) parseLayoutFiles(files ...string) (*thtml.Template, error) {
+	tmpl := thtml.New(filepath.Base(files[0])).Funcs(helpers)
+	if len(gen.partials) > 0 {
+		if _, err := tmpl.ParseFiles(gen.partials...); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl.ParseFiles(files...)
+}
+
+// isBlocksOnly reports whether tmpl has nothing to execute outside the
+// templates it defines.
+func isBlocksOnly(tmpl *thtml.Template) bool {
+	return tmpl.Tree == nil || parse.IsEmptyTree(tmpl.Tree.Root)
+}
+
+// loadPartials lists PartialsDir's *.html files and records their newest
+// mtime. A missing PartialsDir just means no partials.
+func (gen *Generator) loadPartials() error {
+	info, err := os.Stat(PartialsDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	gen.partialsModTime = info.ModTime()
+	// Glob's result is sorted, so template sets are built the same way on
+	// every run.
+	if gen.partials, err = filepath.Glob(filepath.Join(PartialsDir, "*.html")); err != nil {
+		return err
+	}
+	for _, partial := range gen.partials {
+		info, err := os.Stat(partial)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(gen.partialsModTime) {
+			gen.partialsModTime = info.ModTime()
+		}
+	}
+	return nil
+}
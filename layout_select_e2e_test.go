@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutSelection(t *testing.T) {
+	newTestSite(t, "layouts-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	for page, chrome := range map[string]string{
+		"index.html":                          "default-chrome",
+		"launch.html":                         "landing-chrome",
+		filepath.Join("docs", "intro.html"):   "docs-chrome",
+		filepath.Join("docs", "welcome.html"): "landing-chrome",
+	} {
+		out := readDeploy(t, page)
+		if !strings.Contains(out, `class="`+chrome+`"`) {
+			t.Fatalf("%s = %q, want it laid out with %s", page, out, chrome)
+		}
+	}
+}
+
+func TestLayoutNoneWritesProcessedBody(t *testing.T) {
+	newTestSite(t, "layouts-site")
+	src := "<!-- layout: none -->\n<h2>Part</h2>\n<p>{{.Path}} <a href=\"docs/intro.md\">Intro</a></p>\n"
+	if err := os.WriteFile("fragment.html", []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	want := `<h2 id="part">Part</h2>` + "\n" + `<p>/fragment.html <a href="docs/intro.html">Intro</a></p>`
+	if got := readDeploy(t, "fragment.html"); got != want {
+		t.Fatalf("fragment.html = %q, want %q", got, want)
+	}
+}
+
+func TestVerbatimPageIsDeployedAsWritten(t *testing.T) {
+	newTestSite(t, "layouts-site")
+	const doc = `<!DOCTYPE html>
+<html>
+<head><title>{{.Title}}</title></head>
+<body>
+<!-- kept -->
+<embed src="missing.html" type="text/html">
+<svg><path d="M0 0"/></svg>
+</body>
+</html>
+`
+	if err := os.WriteFile("widget.html", []byte("<!-- verbatim: true -->\n"+doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if got := readDeploy(t, "widget.html"); got != doc {
+		t.Fatalf("widget.html = %q, want %q", got, doc)
+	}
+	if got, want := readDeploy(t, "google123.html"), "google-site-verification: google123.html"; got != want {
+		t.Fatalf("google123.html = %q, want %q", got, want)
+	}
+}
+
+func TestLayoutOutsideLayoutsDirFails(t *testing.T) {
+	newTestSite(t, "layouts-site")
+	if err := os.WriteFile("escape.html", []byte("<!-- layout: ../layout.html -->\n<p>x</p>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err == nil || !strings.Contains(err.Error(), "../layout.html") {
+		t.Fatalf("generate() error = %v, want it to reject the layout", err)
+	}
+}
+
+func TestIncrementalRebuildsPagesWhenNamedLayoutChanges(t *testing.T) {
+	newTestSite(t, "layouts-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	pinDeploy(t, past, "index.html")
+
+	rewriteFuture(t, filepath.Join(LayoutsDir, "docs.html"), "Docs sidebar", "Edited sidebar")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, filepath.Join("docs", "intro.html")); !strings.Contains(out, "Edited sidebar") {
+		t.Fatalf("docs/intro.html = %q, want the edited docs layout", out)
+	}
+	if got := deployModTime(t, "index.html"); !got.Equal(past) {
+		t.Fatalf("index.html was rebuilt (mtime %v), want it left alone", got)
+	}
+	if entry := readManifest(t).Outputs["docs/intro.html"]; entry == nil || entry.Layout != ".zas/layouts/docs.html" {
+		t.Fatalf("docs/intro.html entry = %+v, want layout .zas/layouts/docs.html", entry)
+	}
+}
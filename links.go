@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/atom"
+)
+
+// rewriteSourceLinks points every link in doc to a Markdown source at that
+// source's deployed URL, so [more](section/more.md) - which works on GitHub
+// and in editors - keeps working once deployed. A relative href is resolved
+// against the directory of data.embedFile, the file it was written in, as
+// it is in those, and rewritten relative to data.Path, the page it ends up
+// in; one starting with "/" is resolved against the site root and stays
+// absolute. The fragment and query are kept. A link to a source that
+// doesn't exist is left as is, with a warning.
+func (gen *Generator) rewriteSourceLinks(doc *goquery.Document, data *ZasData) {
+	file := data.embedFile
+	if filepath.IsAbs(file) {
+		// An embed's, as resolveEmbedSrc returns it.
+		root, err := filepath.Abs(".")
+		if err != nil {
+			return
+		}
+		if file, err = filepath.Rel(root, file); err != nil {
+			return
+		}
+	}
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href := a.AttrOr(atom.Href.String(), "")
+		u, err := url.Parse(href)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" || !hasExtension(u.Path, ".md") {
+			return
+		}
+		source := u.Path
+		if !strings.HasPrefix(source, "/") {
+			source = path.Join("/", path.Dir(filepath.ToSlash(file)), source)
+		}
+		source = strings.TrimPrefix(path.Clean(source), "/")
+		// A link to any Markdown source makes this page depend on the index,
+		// which changes when a page comes or goes or its URL does.
+		data.usesIndex = true
+		if info, err := os.Stat(filepath.FromSlash(source)); err != nil || !info.Mode().IsRegular() {
+			gen.printLine(file, "=>", fmt.Errorf("link %q: no such source %s", href, source))
+			return
+		}
+		target := gen.sourceURL(filepath.FromSlash(source))
+		if !strings.HasPrefix(u.Path, "/") {
+			target = relativeURL(data.Path, target)
+		}
+		u.Path, u.RawPath = target, ""
+		a.SetAttr(atom.Href.String(), u.String())
+	})
+}
+
+// sourceURL returns the URL path the page at source is deployed to: a
+// listed page's Path, or else its output's.
+func (gen *Generator) sourceURL(source string) string {
+	if page := gen.index.page(source); page != nil {
+		return page.Path
+	}
+	return "/" + gen.outputKey(source)
+}
+
+// relativeURL returns the URL path target relative to from, both URL paths
+// from the site root: "/docs/a.html" to "/blog/b/" is "../blog/b/".
+func relativeURL(from, target string) string {
+	fromDir := strings.Split(strings.Trim(path.Dir(from+"x"), "/"), "/")
+	if fromDir[0] == "" {
+		fromDir = nil
+	}
+	to := strings.Split(strings.TrimPrefix(target, "/"), "/")
+	common := 0
+	for common < len(fromDir) && common < len(to)-1 && fromDir[common] == to[common] {
+		common++
+	}
+	rel := strings.Repeat("../", len(fromDir)-common) + strings.Join(to[common:], "/")
+	if rel == "" {
+		return "./"
+	}
+	return rel
+}
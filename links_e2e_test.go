@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRewritesLinksToMarkdownSources(t *testing.T) {
+	newTestSite(t, "links-site")
+	var err error
+	stderr := captureStderr(t, func() { err = generate(t) })
+	if err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	guide := readDeploy(t, filepath.Join("docs", "guide.html"))
+	for _, want := range []string{
+		`<a href="section/more.html?ref=guide#setup">more</a>`,
+		`<a href="../about.html#team">about</a>`,
+		`<a href="/about.html">rooted</a>`,
+		`<a href="../index.html">home</a>`,
+		`<a href="https://example.org/readme.md">elsewhere</a>`,
+		`<a href="missing.md">gone</a>`,
+	} {
+		if !strings.Contains(guide, want) {
+			t.Errorf("docs/guide.html = %q, want %q", guide, want)
+		}
+	}
+	if home := readDeploy(t, "index.html"); !strings.Contains(home, `<a href="docs/guide.html">guide</a>`) {
+		t.Errorf("index.html = %q, want its link to docs/guide.md rewritten", home)
+	}
+	if want := `link "missing.md": no such source docs/missing.md`; !strings.Contains(stderr, want) {
+		t.Errorf("stderr = %q, want a warning %q", stderr, want)
+	}
+}
+
+func TestGenerateRewritesLinksToPrettyURLs(t *testing.T) {
+	newTestSite(t, "links-site")
+	setZasOption(t, "pretty_urls", "true")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	guide := readDeploy(t, filepath.Join("docs", "guide", "index.html"))
+	for _, want := range []string{
+		`<a href="../section/more/?ref=guide#setup">more</a>`,
+		`<a href="../../about/#team">about</a>`,
+		`<a href="/about/">rooted</a>`,
+		`<a href="../../">home</a>`,
+	} {
+		if !strings.Contains(guide, want) {
+			t.Errorf("docs/guide/index.html = %q, want %q", guide, want)
+		}
+	}
+}
+
+func TestRelativeURL(t *testing.T) {
+	for _, c := range []struct{ from, target, want string }{
+		{"/docs/guide.html", "/docs/more.html", "more.html"},
+		{"/docs/guide.html", "/about.html", "../about.html"},
+		{"/index.html", "/docs/guide.html", "docs/guide.html"},
+		{"/", "/blog/post/", "blog/post/"},
+		{"/about/", "/about/", "./"},
+		{"/docs/guide/", "/", "../../"},
+	} {
+		if got := relativeURL(c.from, c.target); got != c.want {
+			t.Errorf("relativeURL(%q, %q) = %q, want %q", c.from, c.target, got, c.want)
+		}
+	}
+}
+
+func TestGenerateRewritesLinksInEmbedsAgainstTheirOwnDirectory(t *testing.T) {
+	newTestSite(t, "links-site")
+	var err error
+	stderr := captureStderr(t, func() { err = generate(t) })
+	if err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	docs := readDeploy(t, filepath.Join("docs", "index.html"))
+	for _, want := range []string{
+		`<a href="../about.html">About</a>`,
+		`<a href="guide.html#top">Guide</a>`,
+	} {
+		if !strings.Contains(docs, want) {
+			t.Errorf("docs/index.html = %q, want %q", docs, want)
+		}
+	}
+	if strings.Contains(stderr, "docs/about.md") || strings.Contains(stderr, "nav.md =>") {
+		t.Errorf("stderr = %q, want no warning for the embedded nav.md's links", stderr)
+	}
+}
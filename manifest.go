@@ -0,0 +1,361 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// manifest is ManifestFile's content: every deploy output the last run
+// produced, keyed by its slash-separated path relative to the deploy
+// directory. It's the authoritative list of what belongs in deploy - reaper
+// removes anything it doesn't list - and, since every entry carries its
+// content hash, two runs' manifests give a deploy tool the exact set of
+// files that changed between them without rescanning either tree.
+type manifest struct {
+	Outputs map[string]*manifestEntry `json:"outputs"`
+	// Pages is what discoverPages read from every page's source, keyed by
+	// the source's slash-separated path (see pageSource).
+	Pages map[string]*pageSource `json:"pages,omitempty"`
+}
+
+// manifestEntry describes how one deploy output was built.
+type manifestEntry struct {
+	// Source is the site-root-relative, slash-separated path of the file
+	// the output was rendered or copied from.
+	Source string `json:"source"`
+	// Rule is how the output was produced from Source: ruleMarkdown,
+	// ruleHTML, ruleCopy, or one of the generated rules, whose Source is
+	// the config file declaring the output.
+	Rule string `json:"rule"`
+	// Hash is the hex-encoded SHA-256 of the deployed file's content, and
+	// Size its length in bytes.
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+	// Embeds lists every file pulled into the output through an <embed>
+	// handled by Zas itself (Markdown, Plain, Html) - at any nesting depth,
+	// and including embeds written into the layout - as site-root-relative,
+	// slash-separated paths, sorted and deduplicated. A MIME-type plugin's
+	// src is never listed: the plugin decides what it reads, so Zas can't
+	// know what it depends on.
+	Embeds []string `json:"embeds,omitempty"`
+	// Layout is the site-root-relative, slash-separated path of the named
+	// layout the output was rendered into (see layoutFor), empty for the
+	// default layout or none at all.
+	Layout string `json:"layout,omitempty"`
+	// Index is the fingerprint of the page index the output was rendered
+	// with, recorded only if it listed pages through Pages or Section.
+	Index string `json:"index,omitempty"`
+	// Feed is the page's body as the feeds listing it carry it, recorded
+	// only for such a page. It's kept along with the rest of the entry, so
+	// a feed reuses it for as long as the output, and its Hash, stay the
+	// same.
+	Feed string `json:"feed,omitempty"`
+	// Key is the output's staleness key (see stalenessKey), recorded only
+	// when hash staleness is configured.
+	Key string `json:"key,omitempty"`
+}
+
+// Rules a manifestEntry can record. The first three turn one source file
+// into one output; the rest are generated from the site as a whole (see
+// writeGenerated).
+const (
+	ruleMarkdown = "markdown"
+	ruleHTML     = "html"
+	ruleCopy     = "copy"
+	ruleSitemap  = "sitemap"
+	ruleRobots   = "robots"
+	ruleFeed     = "feed"
+	ruleTaxonomy = "taxonomy"
+	ruleRedirect = "redirect"
+)
+
+// ruleFor returns the rule renderAsync dispatches source to.
+func ruleFor(source string) string {
+	switch {
+	case hasExtension(source, ".md"):
+		return ruleMarkdown
+	case hasExtension(source, ".html"):
+		return ruleHTML
+	}
+	return ruleCopy
+}
+
+// loadManifest reads ManifestFile into gen.prevManifest. A missing file is
+// the normal state of a site's first incremental run. A malformed one is
+// treated the same way, rather than failing the build: the manifest is only
+// ever an optimization hint for deciding what to skip, so losing it just
+// means trusting mtimes alone, exactly as every run did before it existed.
+func (gen *Generator) loadManifest() {
+	data, err := os.ReadFile(ManifestFile)
+	if err != nil {
+		return
+	}
+	var m manifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		gen.printLine(ManifestFile, "=>", err, "(ignored)")
+		return
+	}
+	gen.prevManifest = &m
+	gen.prevSources = make(map[string][]string)
+	for output, entry := range m.Outputs {
+		gen.prevSources[entry.Source] = append(gen.prevSources[entry.Source], output)
+	}
+}
+
+// prevEntry returns the previous run's manifest entry for output, if any.
+// prevManifest is loaded once before walk starts and never written after,
+// so no mutex.
+func (gen *Generator) prevEntry(output string) (*manifestEntry, bool) {
+	if gen.prevManifest == nil {
+		return nil, false
+	}
+	entry, ok := gen.prevManifest.Outputs[output]
+	return entry, ok
+}
+
+// recordOutput adds output to the manifest this run is building. It's
+// called from renderAsync goroutines, hence manifestMu.
+func (gen *Generator) recordOutput(output string, entry *manifestEntry) {
+	gen.manifestMu.Lock()
+	defer gen.manifestMu.Unlock()
+	if gen.manifest == nil {
+		gen.manifest = &manifest{Outputs: make(map[string]*manifestEntry)}
+	}
+	gen.manifest.Outputs[output] = entry
+}
+
+// keepOutput carries output's previous manifest entry over into this run's
+// manifest unchanged: for an output walk decided was still fresh, and for
+// one whose render failed this run, whose deploy file - if any - is still
+// the one that entry describes. Without a previous entry (a site's first
+// run with a manifest, or a deleted one) the entry is rebuilt from the
+// deploy file itself, if there is one; with neither, there's nothing in
+// deploy to describe.
+func (gen *Generator) keepOutput(output, source string) {
+	if entry, ok := gen.prevEntry(output); ok {
+		gen.recordOutput(output, entry)
+		return
+	}
+	f, err := os.Open(gen.BuildDeployPath(filepath.FromSlash(output)))
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	d := newDigestWriter(io.Discard)
+	if _, err = io.Copy(d, f); err != nil {
+		return
+	}
+	gen.recordOutput(output, d.entry(source))
+}
+
+// keepPrevOutputs carries every output of the previous run's manifest
+// this run hasn't recorded over into this run's (see keepOutput).
+func (gen *Generator) keepPrevOutputs() {
+	if gen.prevManifest == nil {
+		return
+	}
+	for output, entry := range gen.prevManifest.Outputs {
+		gen.manifestMu.Lock()
+		written := false
+		if gen.manifest != nil {
+			_, written = gen.manifest.Outputs[output]
+		}
+		gen.manifestMu.Unlock()
+		if !written {
+			gen.recordOutput(output, entry)
+		}
+	}
+}
+
+// keepSourceOutputs keeps every output source rendered last run (see
+// keepOutput) that this run hasn't written already: its own, and the
+// further pages of a paginated listing, which a fresh listing still
+// produces, and a failed one is still deployed with.
+func (gen *Generator) keepSourceOutputs(source string) {
+	outputs := []string{gen.outputKey(source)}
+	rule := ruleFor(source)
+	for _, output := range gen.prevSources[filepath.ToSlash(source)] {
+		// A page's redirect stubs list it as their source, but they're
+		// generated anew every run.
+		if output != outputs[0] && gen.prevManifest.Outputs[output].Rule == rule {
+			outputs = append(outputs, output)
+		}
+	}
+	for _, output := range outputs {
+		gen.manifestMu.Lock()
+		written := false
+		if gen.manifest != nil {
+			_, written = gen.manifest.Outputs[output]
+		}
+		gen.manifestMu.Unlock()
+		if !written {
+			gen.keepOutput(output, source)
+		}
+	}
+}
+
+// writeManifest persists this run's manifest as ManifestFile.
+func (gen *Generator) writeManifest() error {
+	m := gen.manifest
+	if m == nil {
+		m = &manifest{Outputs: map[string]*manifestEntry{}}
+	}
+	m.Pages = gen.pageSources
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return gen.atomicWriteFile(ManifestFile, func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// manifestDirs returns every deploy directory, slash-separated and relative
+// to the deploy path, that holds at least one of this run's outputs at any
+// depth.
+func (gen *Generator) manifestDirs() map[string]struct{} {
+	dirs := make(map[string]struct{})
+	if gen.manifest == nil {
+		return dirs
+	}
+	for output := range gen.manifest.Outputs {
+		for dir := path.Dir(output); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+	return dirs
+}
+
+// digestWriter passes everything written through it on to w, hashing and
+// counting it on the way, so an output's manifest entry comes out of the
+// same single pass that writes it.
+type digestWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	size int64
+}
+
+func newDigestWriter(w io.Writer) *digestWriter {
+	return &digestWriter{w: w, hash: sha256.New()}
+}
+
+func (d *digestWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.hash.Write(p[:n])
+	d.size += int64(n)
+	return n, err
+}
+
+// entry returns a manifest entry for source's output, as written so far.
+func (d *digestWriter) entry(source string) *manifestEntry {
+	return &manifestEntry{
+		Source: filepath.ToSlash(source),
+		Rule:   ruleFor(source),
+		Hash:   hex.EncodeToString(d.hash.Sum(nil)),
+		Size:   d.size,
+	}
+}
+
+// deps lists the per-output files entry was built from besides its own
+// source: its embeds and its named layout, if any.
+func (entry *manifestEntry) deps() []string {
+	if entry.Layout == "" {
+		return entry.Embeds
+	}
+	return append(slices.Clone(entry.Embeds), entry.Layout)
+}
+
+// depsChangedSince reports whether any file output embedded or was laid out
+// with last run has been modified at or after since (the output's own
+// mtime), or no longer exists. Each file is stat'd at most once per run - a
+// navigation partial embedded into every page would otherwise be stat'd
+// once per page. Like claimedOutputs, depModTimes is only touched from
+// walk, so no mutex.
+func (gen *Generator) depsChangedSince(output string, since time.Time) bool {
+	entry, ok := gen.prevEntry(output)
+	if !ok {
+		return false
+	}
+	for _, dep := range entry.deps() {
+		modTime, seen := gen.depModTimes[dep]
+		if !seen {
+			if info, err := os.Stat(filepath.FromSlash(dep)); err == nil {
+				modTime = info.ModTime()
+			}
+			if gen.depModTimes == nil {
+				gen.depModTimes = make(map[string]time.Time)
+			}
+			gen.depModTimes[dep] = modTime
+		}
+		// A zero modTime - the file is gone - is never after since, but
+		// the page depending on it must still rebuild, to fail loudly.
+		if modTime.IsZero() || !modTime.Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// embedSet collects the files one page render pulls in through <embed>,
+// as site-root-relative, slash-separated paths.
+type embedSet map[string]struct{}
+
+// trackEmbed records resolved, an absolute path as returned by
+// resolveEmbedSrc, as one of the page's embed dependencies. Every embed
+// handler - nested ones and the layout-level pass in Generate included -
+// is handed the same *ZasData, so they all record into one set.
+func (zd *ZasData) trackEmbed(resolved string) {
+	root, err := filepath.Abs(".")
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil {
+		return
+	}
+	if zd.embeds == nil {
+		zd.embeds = make(embedSet)
+	}
+	zd.embeds[filepath.ToSlash(rel)] = struct{}{}
+}
+
+// sorted returns s's paths in a stable order, so an unchanged page
+// rewrites an identical manifest entry.
+func (s embedSet) sorted() []string {
+	if len(s) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(s))
+	for p := range s {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	return paths
+}
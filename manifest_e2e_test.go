@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readManifest(t *testing.T) manifest {
+	t.Helper()
+	data, err := os.ReadFile(ManifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManifestRecordsEveryOutput(t *testing.T) {
+	newTestSite(t, "site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	m := readManifest(t)
+
+	for output, want := range map[string]struct{ source, rule string }{
+		"about.html":       {"about.md", ruleMarkdown},
+		"index.html":       {"index.html", ruleHTML},
+		"sub/page.html":    {"sub/page.html", ruleHTML},
+		"assets/data.json": {"assets/data.json", ruleCopy},
+	} {
+		entry, ok := m.Outputs[output]
+		if !ok {
+			t.Fatalf("manifest has no %s entry", output)
+		}
+		if entry.Source != want.source || entry.Rule != want.rule {
+			t.Fatalf("%s entry = %+v, want source %s, rule %s", output, entry, want.source, want.rule)
+		}
+		deployed := readDeploy(t, filepath.FromSlash(output))
+		sum := sha256.Sum256([]byte(deployed))
+		if entry.Hash != hex.EncodeToString(sum[:]) || entry.Size != int64(len(deployed)) {
+			t.Fatalf("%s entry hash/size = %s/%d, want the deployed file's own", output, entry.Hash, entry.Size)
+		}
+	}
+	// partials/nav.html opts out with "publish: false".
+	if _, ok := m.Outputs["partials/nav.html"]; ok {
+		t.Fatal("manifest lists partials/nav.html, which is never published")
+	}
+}
+
+func TestReaperRemovesOutputOfPageThatStopsPublishing(t *testing.T) {
+	newTestSite(t, "site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	assertDeployHas(t, "about.html")
+
+	if err := os.WriteFile("about.md", []byte("<!-- publish: false -->\n# About\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchFuture(t, "about.md")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertDeployMissing(t, "about.html")
+}
+
+func TestReaperRemovesFilesNothingProduces(t *testing.T) {
+	newTestSite(t, "site")
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	stray := filepath.Join(".zas", "deploy", "stray", "leftover.txt")
+	if err := os.MkdirAll(filepath.Dir(stray), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stray, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertDeployMissing(t, "stray")
+	assertDeployHas(t, filepath.Join("assets", "data.json"))
+}
+
+func TestIncrementalRebuildsOutputEditedInDeploy(t *testing.T) {
+	newTestSite(t, "site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	target := filepath.Join(".zas", "deploy", "about.html")
+	if err := os.WriteFile(target, []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, "about.html"); out == "tampered" {
+		t.Fatal("about.html still tampered, want it rebuilt from about.md")
+	}
+}
+
+func TestManifestRebuiltWhenMissing(t *testing.T) {
+	newTestSite(t, "site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	want := readManifest(t)
+	if err := os.Remove(ManifestFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	got := readManifest(t)
+	for output, entry := range want.Outputs {
+		if g, ok := got.Outputs[output]; !ok || g.Hash != entry.Hash {
+			t.Fatalf("rebuilt manifest entry for %s = %+v, want hash %s", output, g, entry.Hash)
+		}
+	}
+}
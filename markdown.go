@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	markdown "github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// markdownKey is the section of ConfigFile, and of any DirConfigFile, that
+// toggles goldmark's bundled options:
+//
+//	markdown:
+//	  typographer: true
+//	  definition_list: true
+//	  attributes: true
+//
+// A DirConfigFile's keys win over ConfigFile's for the Markdown files below
+// it, embedded ones included.
+const markdownKey = "markdown"
+
+// markdownOptions is one set of goldmark options. It's comparable, so it
+// keys the converters built for it.
+type markdownOptions struct {
+	typographer    bool
+	definitionList bool
+	linkify        bool
+	cjk            bool
+	attributes     bool
+	hardWraps      bool
+	xhtml          bool
+	highlight      bool
+}
+
+// defaultMarkdownOptions are those of a site without a markdown section:
+// linkify is on, as extension.GFM always had it.
+var defaultMarkdownOptions = markdownOptions{linkify: true}
+
+// markdownOptionKeys maps every markdown section key to its option.
+var markdownOptionKeys = map[string]func(*markdownOptions) *bool{
+	"typographer":     func(o *markdownOptions) *bool { return &o.typographer },
+	"definition_list": func(o *markdownOptions) *bool { return &o.definitionList },
+	"linkify":         func(o *markdownOptions) *bool { return &o.linkify },
+	"cjk":             func(o *markdownOptions) *bool { return &o.cjk },
+	"attributes":      func(o *markdownOptions) *bool { return &o.attributes },
+	"hard_wraps":      func(o *markdownOptions) *bool { return &o.hardWraps },
+	"xhtml":           func(o *markdownOptions) *bool { return &o.xhtml },
+	"highlight":       func(o *markdownOptions) *bool { return &o.highlight },
+}
+
+// markdownConverter passes raw HTML through instead of dropping it - a
+// leading HTML comment (per-page config) and an <embed> tag written
+// directly in a .md file would otherwise never reach Zas. It's the
+// converter for defaultMarkdownOptions, and like every one newMarkdownConverter
+// builds, it's shared across renderAsync goroutines: goldmark builds a
+// fresh parse context per Convert call, so this is safe for concurrent use.
+var markdownConverter = newMarkdownConverter(defaultMarkdownOptions)
+
+// newMarkdownConverter builds a converter with opts on top of what every
+// one has: extension.GFM's tables, strikethrough and task lists, and
+// extension.Footnote - previously neither was enabled, so e.g. a GFM table
+// rendered as a literal paragraph of pipe characters instead of a <table>.
+// Both are purely additive: they recognize syntax CommonMark alone leaves
+// as plain text, so existing content that doesn't use any of it renders
+// exactly as before.
+func newMarkdownConverter(opts markdownOptions) markdown.Markdown {
+	extensions := []markdown.Extender{extension.Table, extension.Strikethrough, extension.TaskList, extension.Footnote}
+	for _, ext := range []struct {
+		on bool
+		markdown.Extender
+	}{
+		{opts.linkify, extension.Linkify},
+		{opts.typographer, extension.Typographer},
+		{opts.definitionList, extension.DefinitionList},
+		{opts.cjk, extension.CJK},
+	} {
+		if ext.on {
+			extensions = append(extensions, ext.Extender)
+		}
+	}
+	var parserOptions []parser.Option
+	if opts.attributes {
+		parserOptions = append(parserOptions, parser.WithAttribute())
+	}
+	rendererOptions := []renderer.Option{
+		renderer.WithNodeRenderers(util.Prioritized(&rawHTMLRenderer{}, 100)),
+	}
+	if opts.highlight {
+		rendererOptions = append(rendererOptions, renderer.WithNodeRenderers(util.Prioritized(&highlightRenderer{}, 100)))
+	}
+	if opts.hardWraps {
+		rendererOptions = append(rendererOptions, html.WithHardWraps())
+	}
+	if opts.xhtml {
+		rendererOptions = append(rendererOptions, html.WithXHTML())
+	}
+	return markdown.New(
+		markdown.WithExtensions(extensions...),
+		markdown.WithParserOptions(parserOptions...),
+		markdown.WithRendererOptions(rendererOptions...),
+	)
+}
+
+// applyMarkdownSection sets opts from section, a markdown section.
+func applyMarkdownSection(opts *markdownOptions, section ConfigSection) error {
+	for key, value := range section {
+		option, ok := markdownOptionKeys[key]
+		if !ok {
+			known := make([]string, 0, len(markdownOptionKeys))
+			for key := range markdownOptionKeys {
+				known = append(known, key)
+			}
+			slices.Sort(known)
+			return fmt.Errorf("%s: unknown option %q (want one of %s)", markdownKey, key, strings.Join(known, ", "))
+		}
+		on, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("%s: %s must be a bool, got %v", markdownKey, key, value)
+		}
+		*option(opts) = on
+	}
+	return nil
+}
+
+// checkMarkdown validates ConfigFile's markdown section, so a typo in it
+// fails the build once rather than once per Markdown file.
+func (gen *Generator) checkMarkdown() error {
+	opts := defaultMarkdownOptions
+	if err := applyMarkdownSection(&opts, gen.Config.GetSection(markdownKey)); err != nil {
+		return fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	return nil
+}
+
+// markdownFor returns the converter for the Markdown file at path (see
+// markdownOptionsFor). path may be absolute, as resolveEmbedSrc returns an
+// embed's.
+func (gen *Generator) markdownFor(path string) (markdown.Markdown, error) {
+	opts, err := gen.markdownOptionsFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return gen.converterFor(opts), nil
+}
+
+// markdownOptionsFor returns the options the Markdown file at path is
+// converted with: ConfigFile's markdown options and then its
+// DirConfigFile's.
+func (gen *Generator) markdownOptionsFor(path string) (markdownOptions, error) {
+	opts := defaultMarkdownOptions
+	if err := applyMarkdownSection(&opts, gen.Config.GetSection(markdownKey)); err != nil {
+		return opts, fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	if filepath.IsAbs(path) {
+		// loadZasDirectoryConfig walks up to the site root, ".".
+		root, err := filepath.Abs(".")
+		if err != nil {
+			return opts, err
+		}
+		if path, err = filepath.Rel(root, path); err != nil {
+			return opts, err
+		}
+	}
+	if dir, _, _ := gen.loadZasDirectoryConfig(path); dir != nil {
+		if err := applyMarkdownSection(&opts, dir.GetSection(markdownKey)); err != nil {
+			return opts, fmt.Errorf("%s: %w", DirConfigFile, err)
+		}
+	}
+	return opts, nil
+}
+
+// converterFor returns the converter for opts. Converters are built once
+// per distinct option set and shared from then on.
+func (gen *Generator) converterFor(opts markdownOptions) markdown.Markdown {
+	if opts == defaultMarkdownOptions {
+		return markdownConverter
+	}
+	gen.markdownMu.Lock()
+	defer gen.markdownMu.Unlock()
+	converter, ok := gen.markdownConverters[opts]
+	if !ok {
+		if gen.markdownConverters == nil {
+			gen.markdownConverters = make(map[markdownOptions]markdown.Markdown)
+		}
+		converter = newMarkdownConverter(opts)
+		gen.markdownConverters[opts] = converter
+	}
+	return converter
+}
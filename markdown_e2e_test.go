@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownOptionsFromSiteConfig(t *testing.T) {
+	newTestSite(t, "markdown-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, "page.html")
+	for _, want := range []string{`<h2 id="opts" class="wide">Options</h2>`, "<p>“Quoted” – and done…\nfirst line\nsecond line</p>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("page.html = %q, want %q", out, want)
+		}
+	}
+}
+
+func TestMarkdownOptionsFromDirConfig(t *testing.T) {
+	newTestSite(t, "markdown-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, filepath.Join("docs", "guide.html"))
+	for _, want := range []string{"<p>&#34;Straight&#34; quotes.<br/>\nfirst line<br/>", "<dt>Term</dt>\n<dd>Definition</dd>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("docs/guide.html = %q, want %q", out, want)
+		}
+	}
+	// An embedded Markdown file gets its own directory's options, not the
+	// embedding page's.
+	if out, want := readDeploy(t, "index.html"), "<p>snippet one<br/>\nsnippet two</p>"; !strings.Contains(out, want) {
+		t.Errorf("index.html = %q, want %q", out, want)
+	}
+}
+
+func TestUnknownMarkdownOptionFails(t *testing.T) {
+	newTestSite(t, "markdown-site")
+	appendConfig(t, "  smartypants: true\n")
+	err := generate(t)
+	if err == nil || !strings.Contains(err.Error(), `markdown: unknown option "smartypants"`) {
+		t.Fatalf("generate() error = %v, want one about the unknown markdown option", err)
+	}
+}
+
+func TestMarkdownConverterPerOptionSet(t *testing.T) {
+	gen := NewGenerator(false, true, true)
+	gen.Config = ConfigSection{markdownKey: ConfigSection{"typographer": true}}
+	first, err := gen.markdownFor("page.md")
+	if err != nil {
+		t.Fatalf("markdownFor() error = %v, want nil", err)
+	}
+	second, _ := gen.markdownFor("other.md")
+	if first != second || first == markdownConverter {
+		t.Fatalf("markdownFor() = %p, %p, want one shared converter other than the default %p", first, second, markdownConverter)
+	}
+}
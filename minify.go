@@ -0,0 +1,850 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	thtml "html/template"
+	"io"
+	"slices"
+	"strings"
+
+	html5 "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// minifyKey is the section of ConfigFile turning minification of deploy
+// output on, one kind of file at a time:
+//
+//	minify:
+//	  html: true
+//	  css: true
+//	  js: true
+//
+// html covers every page Generate writes, laid out or not (see
+// layoutNone); css, js, json and svg the files of that kind copied as they
+// are. A verbatim page (see pageIsVerbatim) is never minified: it's
+// deployed byte for byte as written, which is all it's for.
+const minifyKey = "minify"
+
+// minifyOptions is which kinds of output get minified. The zero value, a
+// site without a minify section, minifies nothing.
+type minifyOptions struct {
+	html bool
+	css  bool
+	js   bool
+	json bool
+	svg  bool
+}
+
+// minifyOptionKeys maps every minify section key to its option.
+var minifyOptionKeys = map[string]func(*minifyOptions) *bool{
+	"html": func(o *minifyOptions) *bool { return &o.html },
+	"css":  func(o *minifyOptions) *bool { return &o.css },
+	"js":   func(o *minifyOptions) *bool { return &o.js },
+	"json": func(o *minifyOptions) *bool { return &o.json },
+	"svg":  func(o *minifyOptions) *bool { return &o.svg },
+}
+
+// loadMinify reads ConfigFile's minify section into gen.minify.
+func (gen *Generator) loadMinify() error {
+	value, ok := gen.Config[minifyKey]
+	if !ok {
+		return nil
+	}
+	section, ok := value.(ConfigSection)
+	if !ok {
+		return fmt.Errorf("%s: %s must be a section, got %v", ConfigFile, minifyKey, value)
+	}
+	var opts minifyOptions
+	for key, value := range section {
+		option, ok := minifyOptionKeys[key]
+		if !ok {
+			known := make([]string, 0, len(minifyOptionKeys))
+			for key := range minifyOptionKeys {
+				known = append(known, key)
+			}
+			slices.Sort(known)
+			return fmt.Errorf("%s: %s: unknown option %q (want one of %s)", ConfigFile, minifyKey, key, strings.Join(known, ", "))
+		}
+		on, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("%s: %s: %s must be a bool, got %v", ConfigFile, minifyKey, key, value)
+		}
+		*option(&opts) = on
+	}
+	gen.minify = opts
+	return nil
+}
+
+// writeHTML writes the page n, a whole document, minified if the site
+// asks for it.
+func (gen *Generator) writeHTML(w io.Writer, n *html5.Node) error {
+	if !gen.minify.html {
+		return html5.Render(w, n)
+	}
+	return minifyHTML(w, n)
+}
+
+// writeBody writes body, a page's body with no layout around it (see
+// layoutNone), minified if the site asks for it.
+func (gen *Generator) writeBody(w io.Writer, body thtml.HTML) error {
+	if !gen.minify.html {
+		_, err := io.WriteString(w, string(body))
+		return err
+	}
+	parent := &html5.Node{Type: html5.ElementNode, Data: atom.Body.String(), DataAtom: atom.Body}
+	nodes, err := html5.ParseFragment(strings.NewReader(string(body)), parent)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		parent.AppendChild(n)
+	}
+	var b bytes.Buffer
+	m := htmlMinifier{b: &b}
+	m.children(parent, false)
+	if m.err != nil {
+		return m.err
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// assetMinifier returns the minifier for the file at path, by extension,
+// or nil if the site doesn't minify its kind.
+func (gen *Generator) assetMinifier(path string) func([]byte) []byte {
+	switch {
+	case gen.minify.css && hasExtension(path, ".css"):
+		return minifyCSS
+	case gen.minify.js && (hasExtension(path, ".js") || hasExtension(path, ".mjs")):
+		return minifyJS
+	case gen.minify.json && hasExtension(path, ".json"):
+		return minifyJSON
+	case gen.minify.svg && hasExtension(path, ".svg"):
+		return minifySVG
+	}
+	return nil
+}
+
+// minifyHTML renders n like html5.Render, but drops comments and the
+// whitespace nothing displays, collapses the rest, omits the end tags
+// HTML lets a document leave out, and leaves attribute values unquoted
+// where it can. What's inside <pre> and <textarea>, and the text of raw
+// text elements like <script> and <style>, is written exactly as parsed,
+// and foreign content - SVG and MathML - as html5.Render writes it.
+func minifyHTML(w io.Writer, n *html5.Node) error {
+	var b bytes.Buffer
+	m := htmlMinifier{b: &b}
+	m.node(n, false)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+type htmlMinifier struct {
+	b   *bytes.Buffer
+	err error
+}
+
+// htmlVoidElements never have an end tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"keygen": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlRawTextElements have their text written as is, never escaped.
+var htmlRawTextElements = map[string]bool{
+	"iframe": true, "noembed": true, "noframes": true, "noscript": true, "plaintext": true, "script": true, "style": true, "xmp": true,
+}
+
+// htmlBlockElements are the elements whitespace next to doesn't display,
+// or that don't display at all.
+var htmlBlockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "base": true, "blockquote": true, "body": true, "br": true,
+	"caption": true, "col": true, "colgroup": true, "dd": true, "details": true, "dialog": true, "div": true,
+	"dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "head": true, "header": true,
+	"hgroup": true, "hr": true, "html": true, "li": true, "link": true, "main": true, "menu": true, "meta": true,
+	"nav": true, "noscript": true, "ol": true, "optgroup": true, "option": true, "p": true, "pre": true,
+	"script": true, "section": true, "style": true, "summary": true, "table": true, "tbody": true, "td": true,
+	"template": true, "tfoot": true, "th": true, "thead": true, "title": true, "tr": true, "ul": true,
+}
+
+// htmlWhitespaceParents only ever hold whitespace that doesn't display
+// between their children.
+var htmlWhitespaceParents = map[string]bool{
+	"colgroup": true, "dl": true, "head": true, "html": true, "ol": true, "optgroup": true, "select": true,
+	"table": true, "tbody": true, "tfoot": true, "thead": true, "tr": true, "ul": true,
+}
+
+// pClosers are the elements whose start lets a preceding </p> go.
+var pClosers = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "details": true, "div": true, "dl": true,
+	"fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hgroup": true, "hr": true, "main": true,
+	"menu": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true, "table": true, "ul": true,
+}
+
+const htmlWhitespace = " \t\n\f\r"
+
+var (
+	htmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#13;")
+	htmlAttrEscaper = strings.NewReplacer("&", "&amp;", `"`, "&#34;", "\r", "&#13;")
+)
+
+// node writes n. verbatim is set inside <pre> and <textarea>, where
+// whitespace displays as written.
+func (m *htmlMinifier) node(n *html5.Node, verbatim bool) {
+	switch n.Type {
+	case html5.DocumentNode:
+		m.children(n, verbatim)
+	case html5.TextNode:
+		m.text(n, verbatim)
+	case html5.CommentNode:
+		// Conditional comments are markup to the browsers reading them.
+		if isConditionalComment(n) {
+			m.render(n)
+		}
+	case html5.ElementNode:
+		if n.Namespace != "" {
+			m.render(n)
+			return
+		}
+		m.element(n, verbatim)
+	default:
+		m.render(n)
+	}
+}
+
+// render writes n as html5.Render does.
+func (m *htmlMinifier) render(n *html5.Node) {
+	if m.err == nil {
+		m.err = html5.Render(m.b, n)
+	}
+}
+
+func (m *htmlMinifier) children(n *html5.Node, verbatim bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		m.node(c, verbatim)
+	}
+}
+
+func (m *htmlMinifier) element(n *html5.Node, verbatim bool) {
+	m.b.WriteByte('<')
+	m.b.WriteString(n.Data)
+	for _, a := range n.Attr {
+		m.b.WriteByte(' ')
+		if a.Namespace != "" {
+			m.b.WriteString(a.Namespace)
+			m.b.WriteByte(':')
+		}
+		m.b.WriteString(a.Key)
+		switch {
+		case a.Val == "":
+		case !strings.ContainsAny(a.Val, htmlWhitespace+"\"'=<>`"):
+			m.b.WriteByte('=')
+			m.b.WriteString(htmlAttrEscaper.Replace(a.Val))
+		default:
+			m.b.WriteString(`="`)
+			m.b.WriteString(htmlAttrEscaper.Replace(a.Val))
+			m.b.WriteByte('"')
+		}
+	}
+	m.b.WriteByte('>')
+	if htmlVoidElements[n.Data] {
+		return
+	}
+	switch n.Data {
+	case "pre", "listing", "textarea":
+		verbatim = true
+		// The parser drops a newline right after the start tag.
+		if c := n.FirstChild; c != nil && c.Type == html5.TextNode && strings.HasPrefix(c.Data, "\n") {
+			m.b.WriteByte('\n')
+		}
+	}
+	if htmlRawTextElements[n.Data] {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html5.TextNode {
+				m.b.WriteString(c.Data)
+			} else {
+				m.node(c, true)
+			}
+		}
+	} else {
+		m.children(n, verbatim)
+	}
+	if !endTagOptional(n) {
+		m.b.WriteString("</")
+		m.b.WriteString(n.Data)
+		m.b.WriteByte('>')
+	}
+}
+
+// text writes the text node n, its whitespace collapsed unless verbatim.
+func (m *htmlMinifier) text(n *html5.Node, verbatim bool) {
+	if n.Parent != nil && n.Parent.Type == html5.ElementNode && htmlRawTextElements[n.Parent.Data] {
+		m.b.WriteString(n.Data)
+		return
+	}
+	if verbatim {
+		m.b.WriteString(htmlTextEscaper.Replace(n.Data))
+		return
+	}
+	if strings.Trim(n.Data, htmlWhitespace) == "" && droppableWhitespace(n) {
+		return
+	}
+	text := collapseWhitespace(n.Data)
+	// Whitespace at either edge of a block displays nothing either.
+	if n.Parent != nil && htmlBlockElements[n.Parent.Data] {
+		if isBlockOrEdge(visibleSibling(n, false)) {
+			text = strings.TrimPrefix(text, " ")
+		}
+		if isBlockOrEdge(visibleSibling(n, true)) {
+			text = strings.TrimSuffix(text, " ")
+		}
+	}
+	m.b.WriteString(htmlTextEscaper.Replace(text))
+}
+
+// collapseWhitespace replaces every run of HTML whitespace in s with a
+// single space.
+func collapseWhitespace(s string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(htmlWhitespace, s[i]) >= 0 {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// droppableWhitespace reports whether n, a whitespace-only text node,
+// displays nothing: its parent never displays whitespace between its
+// children, or it sits between block elements.
+func droppableWhitespace(n *html5.Node) bool {
+	if n.Parent == nil || n.Parent.Type != html5.ElementNode || htmlWhitespaceParents[n.Parent.Data] {
+		return true
+	}
+	return isBlockOrEdge(visibleSibling(n, false)) && isBlockOrEdge(visibleSibling(n, true))
+}
+
+// visibleSibling returns n's next sibling, or previous one unless
+// forward, past any comment minifyHTML drops, or nil.
+func visibleSibling(n *html5.Node, forward bool) *html5.Node {
+	step := func(n *html5.Node) *html5.Node {
+		if forward {
+			return n.NextSibling
+		}
+		return n.PrevSibling
+	}
+	sibling := step(n)
+	for sibling != nil && sibling.Type == html5.CommentNode && !isConditionalComment(sibling) {
+		sibling = step(sibling)
+	}
+	return sibling
+}
+
+// isConditionalComment reports whether n is one of Internet Explorer's
+// conditional comments, which minifyHTML keeps.
+func isConditionalComment(n *html5.Node) bool {
+	return strings.HasPrefix(n.Data, "[if") || strings.HasPrefix(n.Data, "<![endif]")
+}
+
+func isBlockOrEdge(n *html5.Node) bool {
+	return n == nil || n.Type == html5.ElementNode && n.Namespace == "" && htmlBlockElements[n.Data]
+}
+
+// endTagOptional reports whether n's end tag can go, per the HTML
+// standard's optional tags rules, given what minifyHTML writes after it.
+func endTagOptional(n *html5.Node) bool {
+	next := visibleSibling(n, true)
+	if next != nil && next.Type == html5.TextNode && strings.Trim(next.Data, htmlWhitespace) == "" && droppableWhitespace(next) {
+		next = visibleSibling(next, true)
+	}
+	nextIs := func(names ...string) bool {
+		return next != nil && next.Type == html5.ElementNode && next.Namespace == "" && slices.Contains(names, next.Data)
+	}
+	switch n.Data {
+	case "html", "body":
+		return next == nil
+	case "head":
+		return next == nil || next.Type == html5.ElementNode
+	case "li":
+		return next == nil || nextIs("li")
+	case "dt":
+		return nextIs("dt", "dd")
+	case "dd":
+		return next == nil || nextIs("dt", "dd")
+	case "option":
+		return next == nil || nextIs("option", "optgroup")
+	case "tr":
+		return next == nil || nextIs("tr")
+	case "td", "th":
+		return next == nil || nextIs("td", "th")
+	case "thead":
+		return nextIs("tbody", "tfoot")
+	case "tbody":
+		return next == nil || nextIs("tbody", "tfoot")
+	case "p":
+		if next == nil {
+			switch n.Parent.Data {
+			case "a", "audio", "del", "ins", "map", "noscript", "video":
+				return false
+			}
+			return !strings.Contains(n.Parent.Data, "-")
+		}
+		return next.Type == html5.ElementNode && next.Namespace == "" && pClosers[next.Data]
+	}
+	return false
+}
+
+// minifyJSON compacts JSON, or returns it unchanged if it isn't valid.
+func minifyJSON(src []byte) []byte {
+	var b bytes.Buffer
+	if err := json.Compact(&b, src); err != nil {
+		return src
+	}
+	return b.Bytes()
+}
+
+// cssNoSpaceAfter and cssNoSpaceBefore are the bytes whitespace next to
+// means nothing in CSS. Not "(": "and (" in a media query isn't "and(".
+// Not "+", "-" or "*": calc() needs their spaces.
+const (
+	cssNoSpaceAfter  = "{};,>:("
+	cssNoSpaceBefore = "{};,>)!"
+)
+
+// minifyCSS conservatively minifies a stylesheet: comments go, but "/*!"
+// ones, which by convention hold a license; whitespace collapses to one
+// space and goes entirely where it means nothing; and a declaration
+// block's last semicolon goes. Strings and url() are kept as written.
+func minifyCSS(src []byte) []byte {
+	var out bytes.Buffer
+	space := false
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case strings.IndexByte(htmlWhitespace, c) >= 0:
+			space = true
+			i++
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 4
+			}
+			if i+2 < len(src) && src[i+2] == '!' {
+				writeCSSSpace(&out, space, c)
+				out.Write(src[i:end])
+				space = false
+			} else {
+				space = true
+			}
+			i = end
+			continue
+		}
+		writeCSSSpace(&out, space, c)
+		space = false
+		switch {
+		case c == '"' || c == '\'':
+			end := quotedEnd(src, i)
+			out.Write(src[i:end])
+			i = end
+		case (c == 'u' || c == 'U') && bytes.HasPrefix(bytes.ToLower(src[i:min(i+4, len(src))]), []byte("url(")):
+			end := bytes.IndexByte(src[i:], ')')
+			if end < 0 {
+				out.Write(src[i:])
+				i = len(src)
+				continue
+			}
+			if bytes.ContainsAny(src[i+4:i+end], `"'`) {
+				// A quoted url() is a function taking a string.
+				out.Write(src[i : i+4])
+				i += 4
+				continue
+			}
+			out.Write(src[i : i+end+1])
+			i += end + 1
+		case c == '}' && out.Len() > 0 && out.Bytes()[out.Len()-1] == ';':
+			out.Bytes()[out.Len()-1] = '}'
+			i++
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// writeCSSSpace writes the pending whitespace before next, if it means
+// anything.
+func writeCSSSpace(out *bytes.Buffer, space bool, next byte) {
+	if !space || out.Len() == 0 {
+		return
+	}
+	if strings.IndexByte(cssNoSpaceAfter, out.Bytes()[out.Len()-1]) >= 0 || strings.IndexByte(cssNoSpaceBefore, next) >= 0 {
+		return
+	}
+	out.WriteByte(' ')
+}
+
+// quotedEnd returns the index just past the string opening at src[i],
+// backslash escapes and all, or len(src) if it never closes.
+func quotedEnd(src []byte, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			// Unterminated: leave the rest of the line alone.
+			return j
+		}
+	}
+	return len(src)
+}
+
+// jsRegexpAfter are the bytes after which a "/" starts a regular
+// expression rather than dividing, and jsRegexpKeywords the words.
+const jsRegexpAfter = "(,=:[!&|?{};+-*%<>~^"
+
+var jsRegexpKeywords = map[string]bool{
+	"await": true, "case": true, "delete": true, "do": true, "else": true, "in": true, "instanceof": true,
+	"new": true, "of": true, "return": true, "throw": true, "typeof": true, "void": true, "yield": true,
+}
+
+// minifyJS conservatively minifies a script: comments go, every line's
+// indentation goes and blank lines with it, and a run of spaces becomes
+// one, or none where no token could run into the next. Line breaks stay,
+// so automatic semicolon insertion reads it exactly as before; strings,
+// template literals and regular expressions are kept as written.
+func minifyJS(src []byte) []byte {
+	var out bytes.Buffer
+	var (
+		space, newline bool
+		// last is the last byte written but whitespace, and word the last
+		// identifier or keyword, if that's what was written last.
+		last byte
+		word string
+	)
+	flush := func(next byte) {
+		switch {
+		case out.Len() == 0:
+		case newline:
+			out.WriteByte('\n')
+		case space && jsNeedsSpace(last, next):
+			out.WriteByte(' ')
+		}
+		space, newline = false, false
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n' || c == '\r' || c == 0xe2 && jsLineSeparator(src[i:]):
+			newline = true
+			if c == 0xe2 {
+				i += 3
+			} else {
+				i++
+			}
+		case strings.IndexByte(" \t\v\f", c) >= 0:
+			space = true
+			i++
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			if bytes.ContainsAny(src[i:i+2+end], "\n\r") {
+				newline = true
+			} else {
+				space = true
+			}
+			i = min(i+end+4, len(src))
+		case c == '"' || c == '\'':
+			flush(c)
+			end := quotedEnd(src, i)
+			out.Write(src[i:end])
+			i, last, word = end, c, ""
+		case c == '`':
+			flush(c)
+			end := jsTemplateEnd(src, i)
+			out.Write(src[i:end])
+			i, last, word = end, c, ""
+		case c == '/' && (out.Len() == 0 || strings.IndexByte(jsRegexpAfter, last) >= 0 || jsRegexpKeywords[word]):
+			end, ok := jsRegexpEnd(src, i)
+			flush(c)
+			if !ok {
+				out.WriteByte(c)
+				i, last, word = i+1, c, ""
+				continue
+			}
+			out.Write(src[i:end])
+			i, last, word = end, 'a', ""
+		case isJSIdentByte(c):
+			flush(c)
+			start := i
+			for i < len(src) && isJSIdentByte(src[i]) {
+				i++
+			}
+			out.Write(src[start:i])
+			last, word = src[i-1], string(src[start:i])
+		default:
+			flush(c)
+			out.WriteByte(c)
+			i, last, word = i+1, c, ""
+		}
+	}
+	return out.Bytes()
+}
+
+// jsLineSeparator reports whether b starts with U+2028 or U+2029, line
+// terminators to JavaScript.
+func jsLineSeparator(b []byte) bool {
+	return len(b) >= 3 && b[0] == 0xe2 && b[1] == 0x80 && (b[2] == 0xa8 || b[2] == 0xa9)
+}
+
+// isJSIdentByte reports whether c can be part of an identifier, keyword or
+// number - any byte of a non-ASCII character included.
+func isJSIdentByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c == '\\' || c >= 0x80
+}
+
+// jsNeedsSpace reports whether a space between last and next keeps them
+// apart: two words, "+ +", "- -", and anything that'd make a comment.
+func jsNeedsSpace(last, next byte) bool {
+	switch {
+	case isJSIdentByte(last) && isJSIdentByte(next):
+		return true
+	case last == next && (last == '+' || last == '-'):
+		return true
+	case last == '/' || next == '/':
+		return true
+	case isJSIdentByte(last) && (next == '.' || next == '#'):
+		// 1 .toString(), static #field
+		return true
+	case last == '<' && next == '!', last == '-' && next == '>':
+		// <!-- and --> start comments in scripts.
+		return true
+	}
+	return false
+}
+
+// jsTemplateEnd returns the index just past the template literal opening
+// at src[i], substitutions and all.
+func jsTemplateEnd(src []byte, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		switch {
+		case src[j] == '\\':
+			j++
+		case src[j] == '`':
+			return j + 1
+		case src[j] == '$' && j+1 < len(src) && src[j+1] == '{':
+			j = jsSubstitutionEnd(src, j+2) - 1
+		}
+	}
+	return len(src)
+}
+
+// jsSubstitutionEnd returns the index just past the "}" closing a template
+// literal's substitution whose code starts at src[i].
+func jsSubstitutionEnd(src []byte, i int) int {
+	depth := 0
+	for j := i; j < len(src); j++ {
+		switch src[j] {
+		case '"', '\'':
+			j = quotedEnd(src, j) - 1
+		case '`':
+			j = jsTemplateEnd(src, j) - 1
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return j + 1
+			}
+			depth--
+		}
+	}
+	return len(src)
+}
+
+// jsRegexpEnd returns the index just past the regular expression literal
+// opening at src[i], flags and all. It's not one if it doesn't close on
+// its own line.
+func jsRegexpEnd(src []byte, i int) (int, bool) {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '\n', '\r':
+			return 0, false
+		case '/':
+			if class {
+				continue
+			}
+			j++
+			for j < len(src) && isJSIdentByte(src[j]) {
+				j++
+			}
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+// svgPreserveElements are the SVG elements whose whitespace displays, or
+// that aren't SVG inside.
+var svgPreserveElements = map[string]bool{
+	"desc": true, "foreignObject": true, "script": true, "style": true, "text": true, "textPath": true, "title": true, "tspan": true,
+}
+
+// minifySVG conservatively minifies an SVG image: comments go, and so does
+// whitespace-only text between tags, but inside text-holding elements like
+// <text>, or any element declaring xml:space="preserve". Tags, text and
+// CDATA sections are kept as written.
+func minifySVG(src []byte) []byte {
+	var out bytes.Buffer
+	// preserve has one entry per open element: whether whitespace in it
+	// displays.
+	var preserve []bool
+	inPreserve := func() bool { return len(preserve) > 0 && preserve[len(preserve)-1] }
+	for i := 0; i < len(src); {
+		if src[i] != '<' {
+			end := bytes.IndexByte(src[i:], '<')
+			if end < 0 {
+				end = len(src) - i
+			}
+			text := src[i : i+end]
+			if inPreserve() || len(bytes.Trim(text, htmlWhitespace)) > 0 {
+				out.Write(text)
+			}
+			i += end
+			continue
+		}
+		rest := src[i:]
+		var end int
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			if end = bytes.Index(rest, []byte("-->")); end < 0 {
+				return src
+			}
+			i += end + 3
+			continue
+		case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+			if end = bytes.Index(rest, []byte("]]>")); end < 0 {
+				return src
+			}
+			end += 3
+		case bytes.HasPrefix(rest, []byte("<?")):
+			if end = bytes.Index(rest, []byte("?>")); end < 0 {
+				return src
+			}
+			end += 2
+		case bytes.HasPrefix(rest, []byte("<!")):
+			// A DOCTYPE, maybe with an internal subset in brackets.
+			if end = svgDeclEnd(rest); end < 0 {
+				return src
+			}
+		default:
+			if end = svgTagEnd(rest); end < 0 {
+				return src
+			}
+			tag := rest[:end]
+			switch {
+			case bytes.HasPrefix(tag, []byte("</")):
+				if len(preserve) > 0 {
+					preserve = preserve[:len(preserve)-1]
+				}
+			case !bytes.HasSuffix(tag, []byte("/>")):
+				name := tag[1:]
+				if n := bytes.IndexAny(name, htmlWhitespace+"/>"); n >= 0 {
+					name = name[:n]
+				}
+				preserve = append(preserve, inPreserve() || svgPreserveElements[string(name)] ||
+					bytes.Contains(tag, []byte(`xml:space="preserve"`)) || bytes.Contains(tag, []byte(`xml:space='preserve'`)))
+			}
+		}
+		out.Write(rest[:end])
+		i += end
+	}
+	return out.Bytes()
+}
+
+// svgTagEnd returns the length of the tag tag starts with, quoted
+// attribute values and all, or -1 if it never closes.
+func svgTagEnd(tag []byte) int {
+	for j := 1; j < len(tag); j++ {
+		switch tag[j] {
+		case '"', '\'':
+			end := bytes.IndexByte(tag[j+1:], tag[j])
+			if end < 0 {
+				return -1
+			}
+			j += end + 1
+		case '>':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// svgDeclEnd returns the length of the declaration decl starts with, or -1
+// if it never closes.
+func svgDeclEnd(decl []byte) int {
+	depth := 0
+	for j := 2; j < len(decl); j++ {
+		switch decl[j] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '>':
+			if depth <= 0 {
+				return j + 1
+			}
+		}
+	}
+	return -1
+}
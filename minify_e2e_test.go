@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMinifyDeployOutput(t *testing.T) {
+	newTestSite(t, "minify-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	want := `<!DOCTYPE html><html lang=en><head><title>Minified</title><link rel=stylesheet href=/assets/site.css><body><main><h1 id=minified>Minified</h1><p>Some <em>emphasis</em> across lines.<pre><code>keep   this
+  indented
+</code></pre></main><script>
+    var  greeting = "hi";
+  </script>`
+	if got := readDeploy(t, "index.html"); got != want {
+		t.Errorf("index.html =\n%s\nwant\n%s", got, want)
+	}
+	for rel, want := range map[string]string{
+		"site.css":  "body{margin:0}",
+		"app.js":    "function hello(name){\nreturn\"hello \"+name;\n}",
+		"data.json": `{"a":[1,2]}`,
+		"logo.svg":  `<svg xmlns="http://www.w3.org/2000/svg"><circle r="1"/></svg>`,
+		"notes.txt": "left    as    is\n",
+	} {
+		if got := readDeploy(t, filepath.Join("assets", rel)); got != want {
+			t.Errorf("assets/%s = %q, want %q", rel, got, want)
+		}
+	}
+	if entry := readManifest(t).Outputs["assets/site.css"]; entry == nil || entry.Size != int64(len("body{margin:0}")) {
+		t.Errorf("manifest entry for assets/site.css = %+v, want the minified size", entry)
+	}
+}
+
+func TestMinifyLayoutNoneButNotVerbatimPages(t *testing.T) {
+	newTestSite(t, "minify-site")
+	const page = "<p>\n  kept   as <b>written</b>\n</p>\n"
+	for name, config := range map[string]string{"fragment.html": "layout: none", "verbatim.html": "verbatim: true"} {
+		if err := os.WriteFile(name, []byte("<!-- "+config+" -->\n"+page), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if got, want := readDeploy(t, "fragment.html"), "<p>kept as <b>written</b>"; got != want {
+		t.Errorf("fragment.html = %q, want %q", got, want)
+	}
+	if got := readDeploy(t, "verbatim.html"); got != page {
+		t.Errorf("verbatim.html = %q, want %q", got, page)
+	}
+}
+
+func TestMinifyIsOptIn(t *testing.T) {
+	newTestSite(t, "minify-site")
+	ageSources(t, -time.Hour)
+	data, err := os.ReadFile(ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _, _ := strings.Cut(string(data), "minify:")
+	if err := os.WriteFile(ConfigFile, []byte(before), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if got, want := readDeploy(t, filepath.Join("assets", "site.css")), "/* site styles */"; !strings.HasPrefix(got, want) {
+		t.Errorf("assets/site.css = %q, want it copied as is", got)
+	}
+	if got := readDeploy(t, "index.html"); !strings.Contains(got, `<html lang="en">`) {
+		t.Errorf("index.html = %q, want it written as html5.Render does", got)
+	}
+
+	// Turning minification on is a config change: assets are copied again.
+	if err := os.WriteFile(ConfigFile, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchFuture(t, ConfigFile)
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if got, want := readDeploy(t, filepath.Join("assets", "site.css")), "body{margin:0}"; got != want {
+		t.Errorf("assets/site.css = %q, want %q", got, want)
+	}
+}
+
+func TestMinifyRejectsBadConfig(t *testing.T) {
+	for _, tc := range []struct{ yaml, want string }{
+		{"minify: true\n", "minify must be a section"},
+		{"minify:\n  images: true\n", `minify: unknown option "images"`},
+		{"minify:\n  css: yes please\n", "minify: css must be a bool"},
+	} {
+		t.Run(tc.want, func(t *testing.T) {
+			newTestSite(t, "minify-site")
+			data, err := os.ReadFile(ConfigFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before, _, _ := strings.Cut(string(data), "minify:")
+			if err := os.WriteFile(ConfigFile, []byte(before+tc.yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := generate(t); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("generate() error = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	html5 "golang.org/x/net/html"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	for name, tc := range map[string]struct{ in, want string }{
+		"document": {
+			"<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n  <title> A  page </title>\n  <!-- note -->\n</head>\n<body class=\"a b\">\n  <p>Some   <b>bold</b>\n  text</p>\n</body>\n</html>\n",
+			`<!DOCTYPE html><html lang=en><head><title>A page</title><body class="a b"><p>Some <b>bold</b> text`,
+		},
+		"lists": {
+			"<ul>\n  <li>one</li>\n  <li>two</li>\n</ul><dl><dt>t</dt><dd>d</dd></dl>",
+			`<ul><li>one<li>two</ul><dl><dt>t<dd>d</dl>`,
+		},
+		"tables": {
+			"<table>\n<tr><td>a</td><td>b</td></tr>\n<tr><th>c</th></tr>\n</table>",
+			`<table><tbody><tr><td>a<td>b<tr><th>c</table>`,
+		},
+		"paragraph in link": {
+			`<a href="/x"><p>a</p></a><p>b</p><div>c</div>`,
+			`<a href=/x><p>a</p></a><p>b<div>c</div>`,
+		},
+		"attributes": {
+			`<input type="checkbox" checked="" value="a=b"><img alt='it&#39;s' src="x.png">`,
+			`<input type=checkbox checked value="a=b"><img alt="it's" src=x.png>`,
+		},
+		"preserved": {
+			"<pre>\n\n  a  b\n</pre><textarea>  x\n y </textarea><script>if (a  <  b) {\n  go()\n}</script>",
+			"<pre>\n\n  a  b\n</pre><textarea>  x\n y </textarea><script>if (a  <  b) {\n  go()\n}</script>",
+		},
+		"inline spacing": {
+			"<p><b>a</b> <i>b</i> <!-- c --> <span>d</span></p>",
+			`<p><b>a</b> <i>b</i>  <span>d</span>`,
+		},
+		"conditional comment": {
+			"<p>a</p><!--[if IE]><p>old</p><![endif]-->",
+			`<p>a</p><!--[if IE]><p>old</p><![endif]-->`,
+		},
+	} {
+		doc, err := html5.Parse(strings.NewReader(tc.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := minifyHTML(&b, doc); err != nil {
+			t.Fatalf("%s: minifyHTML() error = %v", name, err)
+		}
+		got := b.String()
+		got = strings.TrimPrefix(got, "<html><head><body>")
+		if got != tc.want {
+			t.Errorf("%s: minifyHTML(%q) = %q, want %q", name, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMinifyAssets(t *testing.T) {
+	for name, tc := range map[string]struct {
+		minify   func([]byte) []byte
+		in, want string
+	}{
+		"css": {minifyCSS,
+			"/* c */\na > b ,  c {\n  color: red ;\n  margin: calc(1px + 2px) !important;\n}\n@media screen and (min-width: 600px) { a:hover { x: y } }\n",
+			"a>b,c{color:red;margin:calc(1px + 2px)!important}@media screen and (min-width:600px){a:hover{x:y}}"},
+		"css strings": {minifyCSS,
+			`/*! license */ a::after { content: "  /* x */  " ; background: url( a b.png ) }`,
+			`/*! license */ a::after{content:"  /* x */  ";background:url( a b.png )}`},
+		"css descendant pseudo": {minifyCSS, "a :hover {}", "a :hover{}"},
+		"js": {minifyJS,
+			"// c\nfunction f(a, b) {\n    /* c */ return a + +b\n}\n\nconst s = 'a  b' // d\n",
+			"function f(a,b){\nreturn a+ +b\n}\nconst s='a  b'"},
+		"js regexp": {minifyJS,
+			"x = a / b / c; y = s.replace(/ +\\/\\// , '') ;return  /[/]  x/g",
+			"x=a / b / c;y=s.replace(/ +\\/\\//,'');return /[/]  x/g"},
+		"js template": {minifyJS,
+			"const t = `a  ${ x + `b  ${y}` }\n  c` ;",
+			"const t=`a  ${ x + `b  ${y}` }\n  c`;"},
+		"json":         {minifyJSON, "{\n  \"a\": [1, 2],\n  \"b\": \"c d\"\n}\n", `{"a":[1,2],"b":"c d"}`},
+		"json invalid": {minifyJSON, "{a: 1}\n", "{a: 1}\n"},
+		"svg": {minifySVG,
+			"<?xml version=\"1.0\"?>\n<!-- c -->\n<svg xmlns=\"http://www.w3.org/2000/svg\">\n  <g>\n    <path d=\"M0 0 L1 1\"/>\n  </g>\n  <text x=\"0\"><tspan>a</tspan> <tspan>b</tspan></text>\n</svg>\n",
+			"<?xml version=\"1.0\"?><svg xmlns=\"http://www.w3.org/2000/svg\"><g><path d=\"M0 0 L1 1\"/></g><text x=\"0\"><tspan>a</tspan> <tspan>b</tspan></text></svg>"},
+	} {
+		if got := string(tc.minify([]byte(tc.in))); got != tc.want {
+			t.Errorf("%s: minify(%q) = %q, want %q", name, tc.in, got, tc.want)
+		}
+	}
+}
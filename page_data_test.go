@@ -190,7 +190,7 @@ func TestRenderPageBodyCanReadFirstTitle(t *testing.T) {
 		t.Fatalf("render() error = %v, want nil", err)
 	}
 	out := readRenderedFile(t, "page.html")
-	if !strings.Contains(out, "<h1>Heading Text</h1>") {
+	if !strings.Contains(out, `<h1 id="heading-text">Heading Text</h1>`) {
 		t.Fatalf("deploy output = %q, want the literal heading preserved", out)
 	}
 	if !strings.Contains(out, "<p>Heading Text</p>") {
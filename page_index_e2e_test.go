@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPageIndexListsSections(t *testing.T) {
+	newTestSite(t, "index-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if out, want := readDeploy(t, "index.html"), `<ul class="latest"><li>Gamma post</li><li>Beta post</li></ul>`; !strings.Contains(out, want) {
+		t.Fatalf("index.html = %q, want %q", out, want)
+	}
+	if out, want := readDeploy(t, filepath.Join("blog", "index.html")), "<ol><li>Alpha post</li><li>Beta post</li><li>Gamma post</li></ol>"; !strings.Contains(out, want) {
+		t.Fatalf("blog/index.html = %q, want %q (no draft, no section index)", out, want)
+	}
+}
+
+func TestPageIndexInLayout(t *testing.T) {
+	newTestSite(t, "index-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	want := `<nav><a href="/index.html">Home</a><a href="/about.html">About us</a></nav>`
+	if out := readDeploy(t, filepath.Join("docs", "guide.html")); !strings.Contains(out, want) {
+		t.Fatalf("docs/guide.html = %q, want %q", out, want)
+	}
+}
+
+func TestIncrementalRebuildsIndexUsersWhenIndexChanges(t *testing.T) {
+	newTestSite(t, "index-site")
+	// The nav lists pages in every layout; drop it so only the pages
+	// listing a section depend on the index.
+	if err := os.WriteFile(LayoutFile, []byte("<html><body>{{.Body}}</body></html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	pinDeploy(t, past, "about.html")
+
+	if err := os.WriteFile(filepath.Join("blog", "delta.md"), []byte("<!-- date: 2024-04-01 -->\n# Delta post\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchFuture(t, filepath.Join("blog", "delta.md"))
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, "index.html"); !strings.Contains(out, "<li>Delta post</li><li>Gamma post</li>") {
+		t.Fatalf("index.html = %q, want the new post listed first", out)
+	}
+	if out := readDeploy(t, filepath.Join("blog", "index.html")); !strings.Contains(out, "<li>Delta post</li>") {
+		t.Fatalf("blog/index.html = %q, want the new post listed", out)
+	}
+	if got := deployModTime(t, "about.html"); !got.Equal(past) {
+		t.Fatalf("about.html was rebuilt (mtime %v), want it left alone", got)
+	}
+}
+
+func TestIncrementalDiscoveryReusesUnchangedPages(t *testing.T) {
+	newTestSite(t, "index-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	m := readManifest(t)
+	alpha := m.Pages["blog/alpha.md"]
+	if alpha == nil || alpha.Title != "Alpha post" || alpha.Config != " date: 2024-01-10 " {
+		t.Fatalf("manifest page blog/alpha.md = %+v, want its title and config comment", alpha)
+	}
+	// A title only the manifest has shows the unchanged source isn't
+	// converted again.
+	alpha.Title = "Recorded title"
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ManifestFile, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, filepath.Join("blog", "index.html")); !strings.Contains(out, "<li>Recorded title</li>") {
+		t.Fatalf("blog/index.html = %q, want the title recorded for the unchanged page", out)
+	}
+
+	rewriteFuture(t, filepath.Join("blog", "alpha.md"), "# Alpha post", "# Alpha post, edited")
+	if err := generate(t); err != nil {
+		t.Fatalf("third generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, filepath.Join("blog", "index.html")); !strings.Contains(out, "<li>Alpha post, edited</li>") {
+		t.Fatalf("blog/index.html = %q, want the edited page read again", out)
+	}
+}
+
+func TestPageListSorting(t *testing.T) {
+	a := &PageInfo{Path: "/a.html", Title: "Zed", Weight: 2, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	b := &PageInfo{Path: "/b.html", Title: "Alpha", Weight: 1, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := &PageInfo{Path: "/c.html", Title: "Mid", Weight: 1, Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}
+	pages := PageList{a, b, c}
+	paths := func(pl PageList) string {
+		var ps []string
+		for _, p := range pl {
+			ps = append(ps, p.Path)
+		}
+		return strings.Join(ps, " ")
+	}
+	for name, tc := range map[string]struct {
+		got  PageList
+		want string
+	}{
+		"ByDate":        {pages.ByDate(), "/b.html /c.html /a.html"},
+		"ByWeight":      {pages.ByWeight(), "/b.html /c.html /a.html"},
+		"ByTitle":       {pages.ByTitle(), "/b.html /c.html /a.html"},
+		"Reverse":       {pages.Reverse(), "/c.html /b.html /a.html"},
+		"Limit":         {pages.ByDate().Reverse().Limit(2), "/a.html /c.html"},
+		"LimitPastSize": {pages.Limit(5), "/a.html /b.html /c.html"},
+	} {
+		if got := paths(tc.got); got != tc.want {
+			t.Errorf("%s = %s, want %s", name, got, tc.want)
+		}
+	}
+	if paths(pages) != "/a.html /b.html /c.html" {
+		t.Fatalf("sorting reordered the original list: %s", paths(pages))
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Paginator is one page of a listing page declaring "paginate: N" in its
+// config comment, as {{.Paginator}} exposes it. The listing is every
+// published page in the listing page's own directory and below it, newest
+// first by date, but the listing page itself and its directory's
+// index.html. Page 1 is the listing page's own output; page n is written to
+// page/n/index.html next to it - under a directory named after it, unless
+// it's an index.html itself or has a pretty URL, a directory already:
+//
+//	blog/index.html -> blog/page/2/index.html
+//	archive.html    -> archive/page/2/index.html
+//	/archive/       -> archive/page/2/index.html
+type Paginator struct {
+	// Items are the pages listed on this page.
+	Items PageList
+	// Number is this page's number, counting from 1, out of TotalPages.
+	Number     int
+	TotalPages int
+	// PerPage is the configured "paginate", and TotalItems the length of
+	// the whole listing.
+	PerPage    int
+	TotalItems int
+	// First, Last, Prev and Next are the URLs of the first, last, previous
+	// and next pages, with the site's base URL like ZasData.URL. Prev is
+	// empty on the first page and Next on the last.
+	First string
+	Last  string
+	Prev  string
+	Next  string
+}
+
+// pagination is a paginated listing page, as discoverPages found it.
+type pagination struct {
+	page    *PageInfo
+	perPage int
+	items   PageList
+}
+
+// loadPaginations finds every page in pages declaring "paginate", keyed by
+// source path.
+func loadPaginations(pages PageList) (map[string]*pagination, error) {
+	var paginations map[string]*pagination
+	for _, page := range pages {
+		value, ok := page.Page["paginate"]
+		if !ok {
+			continue
+		}
+		perPage, ok := value.(int)
+		if !ok || perPage < 1 {
+			return nil, fmt.Errorf("%s: paginate must be a positive integer, got %v", page.source, value)
+		}
+		// The listing's directory is its source's: with a pretty URL, a
+		// page's Path is a directory of its own.
+		prefix := "/"
+		if dir := filepath.Dir(page.source); dir != "." {
+			prefix += filepath.ToSlash(dir) + "/"
+		}
+		var items PageList
+		for _, item := range pages {
+			if item != page && strings.HasPrefix(item.Path, prefix) && !item.isIndexOf(prefix) {
+				items = append(items, item)
+			}
+		}
+		if paginations == nil {
+			paginations = make(map[string]*pagination)
+		}
+		paginations[page.source] = &pagination{
+			page:    page,
+			perPage: perPage,
+			items:   items.sorted(func(a, b *PageInfo) int { return b.Date.Compare(a.Date) }),
+		}
+	}
+	return paginations, nil
+}
+
+// pagination returns the pagination of the page at source, or nil if it
+// isn't paginated.
+func (gen *Generator) pagination(source string) *pagination {
+	if gen.index == nil {
+		return nil
+	}
+	return gen.index.paginations[source]
+}
+
+// totalPages is how many pages p's listing takes: at least one, even for
+// an empty listing, since the listing page itself is always written.
+func (p *pagination) totalPages() int {
+	return max(1, (len(p.items)+p.perPage-1)/p.perPage)
+}
+
+// pagePath returns the URL path of p's page number.
+func (p *pagination) pagePath(number int) string {
+	if number == 1 {
+		return p.page.Path
+	}
+	base := strings.TrimSuffix(p.page.Path, "index.html")
+	if !strings.HasSuffix(base, "/") {
+		base = strings.TrimSuffix(base, path.Ext(base)) + "/"
+	}
+	if strings.HasSuffix(p.page.Path, "/") {
+		return base + "page/" + strconv.Itoa(number) + "/"
+	}
+	return base + "page/" + strconv.Itoa(number) + "/index.html"
+}
+
+// extraOutputs returns the deploy paths of every page of p but the first,
+// which is the listing page's own output.
+func (p *pagination) extraOutputs() []string {
+	var outputs []string
+	for number := 2; number <= p.totalPages(); number++ {
+		outputs = append(outputs, filepath.FromSlash(outputOf(p.pagePath(number))))
+	}
+	return outputs
+}
+
+// paginator returns p's page number.
+func (p *pagination) paginator(number int, baseURL string) *Paginator {
+	total := p.totalPages()
+	start := min((number-1)*p.perPage, len(p.items))
+	end := min(start+p.perPage, len(p.items))
+	url := func(number int) string {
+		return strings.TrimRight(baseURL, "/") + p.pagePath(number)
+	}
+	paginator := &Paginator{
+		Items:      p.items[start:end:end],
+		Number:     number,
+		TotalPages: total,
+		PerPage:    p.perPage,
+		TotalItems: len(p.items),
+		First:      url(1),
+		Last:       url(total),
+	}
+	if number > 1 {
+		paginator.Prev = url(number - 1)
+	}
+	if number < total {
+		paginator.Next = url(number + 1)
+	}
+	return paginator
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaginationWritesEveryPage(t *testing.T) {
+	newTestSite(t, "pagination-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	for rel, want := range map[string]string{
+		filepath.Join("blog", "index.html"):                 "<ul><li>Post 5</li><li>Post 4</li></ul>",
+		filepath.Join("blog", "page", "2", "index.html"):    "<ul><li>Post 3</li><li>Post 2</li></ul>",
+		filepath.Join("blog", "page", "3", "index.html"):    "<ul><li>Post 1</li></ul>",
+		filepath.Join("archive", "page", "2", "index.html"): "<ul><li>/blog/post1.html</li><li>/blog/index.html</li></ul>",
+	} {
+		if out := readDeploy(t, rel); !strings.Contains(out, want) {
+			t.Errorf("%s = %q, want %q", rel, out, want)
+		}
+	}
+	for rel, want := range map[string]string{
+		filepath.Join("blog", "index.html"):              "1/3 prev= next=http://example.com/blog/page/2/index.html",
+		filepath.Join("blog", "page", "2", "index.html"): "2/3 prev=http://example.com/blog/index.html next=http://example.com/blog/page/3/index.html",
+		filepath.Join("blog", "page", "3", "index.html"): "3/3 prev=http://example.com/blog/page/2/index.html next=",
+	} {
+		if out := readDeploy(t, rel); !strings.Contains(out, want) {
+			t.Errorf("%s = %q, want pager %q", rel, out, want)
+		}
+	}
+	assertDeployMissing(t, filepath.Join("archive", "page", "3", "index.html"))
+	if entry, ok := readManifest(t).Outputs["blog/page/2/index.html"]; !ok || entry.Source != "blog/index.html" {
+		t.Fatalf("manifest entry for blog/page/2/index.html = %+v, want one sourced from blog/index.html", entry)
+	}
+}
+
+func TestIncrementalPaginationKeepsAndReapsPages(t *testing.T) {
+	newTestSite(t, "pagination-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	// Nothing changed: the listing is fresh, and its further pages must
+	// survive along with it.
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertDeployHas(t, filepath.Join("blog", "page", "3", "index.html"))
+
+	for _, name := range []string{"post4.md", "post5.md"} {
+		if err := os.Remove(filepath.Join("blog", name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("third generate() error = %v, want nil", err)
+	}
+	assertDeployMissing(t, filepath.Join("blog", "page", "3"))
+	if out, want := readDeploy(t, filepath.Join("blog", "page", "2", "index.html")), "<ul><li>Post 1</li></ul>"; !strings.Contains(out, want) {
+		t.Fatalf("blog/page/2/index.html = %q, want %q", out, want)
+	}
+}
+
+func TestPaginationRejectsBadPerPage(t *testing.T) {
+	newTestSite(t, "pagination-site")
+	if err := os.WriteFile("archive.html", []byte("<!-- paginate: lots -->\n<h1>Archive</h1>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := generate(t)
+	if err == nil || !strings.Contains(err.Error(), "paginate must be a positive integer") {
+		t.Fatalf("generate() error = %v, want a paginate error", err)
+	}
+}
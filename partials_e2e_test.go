@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutsSharePartials(t *testing.T) {
+	newTestSite(t, "partials-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	index := readDeploy(t, "index.html")
+	for _, want := range []string{"<title>Home</title>", "<header>Shared header</header>", "<footer>Shared footer</footer>", `<main><h1 id="home">Home</h1>`} {
+		if !strings.Contains(index, want) {
+			t.Fatalf("index.html = %q, want %q", index, want)
+		}
+	}
+	if bare := readDeploy(t, "bare.html"); !strings.Contains(bare, "<title>Bare</title>") || !strings.Contains(bare, `class="bare"`) {
+		t.Fatalf("bare.html = %q, want the bare layout using the meta partial", bare)
+	}
+}
+
+func TestBlocksOnlyLayoutOverridesDefault(t *testing.T) {
+	newTestSite(t, "partials-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, filepath.Join("blog", "first.html"))
+	for _, want := range []string{"<header>Shared header</header>", `<main><article class="post"><h1 id="first-post">First post</h1>`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("blog/first.html = %q, want %q", out, want)
+		}
+	}
+}
+
+func TestIncrementalRebuildsPagesWhenPartialChanges(t *testing.T) {
+	newTestSite(t, "partials-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	rewriteFuture(t, filepath.Join(PartialsDir, "header.html"), "Shared header", "Edited header")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	for _, page := range []string{"index.html", filepath.Join("blog", "first.html")} {
+		if out := readDeploy(t, page); !strings.Contains(out, "Edited header") {
+			t.Fatalf("%s = %q, want the edited header partial", page, out)
+		}
+	}
+}
+
+func TestHashStalenessRebuildsPagesWhenPartialChanges(t *testing.T) {
+	newTestSite(t, "partials-site")
+	setZasOption(t, "staleness", stalenessHash)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	footer := filepath.Join(PartialsDir, "footer.html")
+	if err := os.WriteFile(footer, []byte("<footer>Edited footer</footer>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(footer, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, "index.html"); !strings.Contains(out, "Edited footer") {
+		t.Fatalf("index.html = %q, want the edited footer partial", out)
+	}
+}
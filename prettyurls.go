@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// prettyURLsKey turns pretty URLs on or off: site-wide in the zas section,
+// or for a directory or a single page in its DirConfigFile or page config,
+// the nearest one winning. With pretty URLs, a page renders to its own
+// directory's index.html - about.md to about/index.html - and its Path is
+// that directory's, "/about/", so links need no server rewrite rules.
+const prettyURLsKey = "pretty_urls"
+
+// prettyURLs reports whether the page at source, with config page, gets a
+// pretty URL.
+func (gen *Generator) prettyURLs(source string, page map[interface{}]interface{}) bool {
+	if pretty, ok := page[prettyURLsKey].(bool); ok {
+		return pretty
+	}
+	if dir, _, _ := gen.loadZasDirectoryConfig(source); dir != nil {
+		if pretty, ok := dir.GetBoolOK(prettyURLsKey); ok {
+			return pretty
+		}
+	}
+	return gen.sitePrettyURLs()
+}
+
+// sitePrettyURLs reports whether pretty URLs are on site-wide, as for
+// generated pages, which have no config of their own.
+func (gen *Generator) sitePrettyURLs() bool {
+	pretty, _ := gen.Config.GetSection(Name).GetBoolOK(prettyURLsKey)
+	return pretty
+}
+
+// prettyPath returns the pretty form of urlPath, a page's URL path:
+// "/about.html" and "/about/index.html" both become "/about/".
+func prettyPath(urlPath string) string {
+	if dir, ok := strings.CutSuffix(urlPath, "/index.html"); ok {
+		return dir + "/"
+	}
+	return strings.TrimSuffix(urlPath, path.Ext(urlPath)) + "/"
+}
+
+// outputOf returns the slash-separated deploy path a page with URL path
+// urlPath is written to: its index.html for a pretty one, ending in "/".
+func outputOf(urlPath string) string {
+	output := strings.TrimPrefix(urlPath, "/")
+	if output == "" || strings.HasSuffix(output, "/") {
+		output += "index.html"
+	}
+	return output
+}
+
+// outputKey is the manifest key for the deploy output path renders or
+// copies to - the same mapping walk claims outputs with. A listed page's
+// follows its Path, pretty or not; any other source's is its own path,
+// with Markdown's extension swapped for ".html".
+func (gen *Generator) outputKey(source string) string {
+	if page := gen.index.page(source); page != nil {
+		return outputOf(page.Path)
+	}
+	return filepath.ToSlash(swapExtension(source, ".md", ".html"))
+}
+
+// isIndexOf reports whether page is the index of the section whose URL
+// paths start with prefix, pretty or not.
+func (page *PageInfo) isIndexOf(prefix string) bool {
+	return page.Path == prefix || page.Path == prefix+"index.html"
+}
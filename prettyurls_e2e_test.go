@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrettyURLsWritePagesAsDirectories(t *testing.T) {
+	newTestSite(t, "pretty-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	for rel, want := range map[string]string{
+		"index.html":                                 "/ http://example.com/ home=true",
+		filepath.Join("about", "index.html"):         "/about/ http://example.com/about/ home=false",
+		"raw.html":                                   "/raw.html http://example.com/raw.html home=false",
+		filepath.Join("legacy", "old.html"):          "/legacy/old.html http://example.com/legacy/old.html home=false",
+		filepath.Join("blog", "index.html"):          "/blog/ http://example.com/blog/ home=false",
+		filepath.Join("blog", "post1", "index.html"): "/blog/post1/ http://example.com/blog/post1/ home=false",
+	} {
+		if out := readDeploy(t, rel); !strings.Contains(out, want) {
+			t.Errorf("%s = %q, want %q", rel, out, want)
+		}
+	}
+	assertDeployMissing(t, "about.html")
+	assertDeployMissing(t, "raw")
+	if entry, ok := readManifest(t).Outputs["about/index.html"]; !ok || entry.Source != "about.md" {
+		t.Fatalf("manifest entry for about/index.html = %+v, want one sourced from about.md", entry)
+	}
+}
+
+func TestPrettyURLsPaginate(t *testing.T) {
+	newTestSite(t, "pretty-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	for rel, want := range map[string]string{
+		filepath.Join("blog", "index.html"):              "<li>/blog/post2/</li></ul>\n<p class=\"pager\">prev= next=http://example.com/blog/page/2/</p>",
+		filepath.Join("blog", "page", "2", "index.html"): "<li>/blog/post1/</li></ul>\n<p class=\"pager\">prev=http://example.com/blog/ next=</p>",
+	} {
+		if out := readDeploy(t, rel); !strings.Contains(out, want) {
+			t.Errorf("%s = %q, want %q", rel, out, want)
+		}
+	}
+}
+
+func TestSwitchingPrettyURLsReapsOldOutputs(t *testing.T) {
+	newTestSite(t, "pretty-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	// Nothing changed: pretty outputs must be found fresh, not re-rendered
+	// or reaped.
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertDeployHas(t, filepath.Join("about", "index.html"))
+
+	data, err := os.ReadFile(ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigFile, []byte(strings.Replace(string(data), "pretty_urls: true", "pretty_urls: false", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("third generate() error = %v, want nil", err)
+	}
+	if out, want := readDeploy(t, "about.html"), "/about.html http://example.com/about.html"; !strings.Contains(out, want) {
+		t.Errorf("about.html = %q, want %q", out, want)
+	}
+	assertDeployMissing(t, "about")
+	assertDeployMissing(t, filepath.Join("blog", "post1"))
+	assertDeployHas(t, filepath.Join("blog", "page", "2", "index.html"))
+}
+
+func TestPrettyPath(t *testing.T) {
+	for urlPath, want := range map[string]string{
+		"/index.html":      "/",
+		"/about.html":      "/about/",
+		"/blog/index.html": "/blog/",
+		"/blog/post.html":  "/blog/post/",
+	} {
+		if got := prettyPath(urlPath); got != want {
+			t.Errorf("prettyPath(%q) = %q, want %q", urlPath, got, want)
+		}
+		if got, want := outputOf(want), strings.TrimPrefix(want, "/")+"index.html"; got != want {
+			t.Errorf("outputOf(%q) = %q, want %q", prettyPath(urlPath), got, want)
+		}
+	}
+}
+
+func TestPrettyURLsTaxonomyPages(t *testing.T) {
+	newTestSite(t, "taxonomy-site")
+	setZasOption(t, "pretty_urls", "true")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	goTerm := readDeploy(t, filepath.Join("tags", "go", "index.html"))
+	if want := `<li><a href="/posts/again/">Go again</a></li><li><a href="/posts/go/">Go on the web</a></li>`; !strings.Contains(goTerm, want) {
+		t.Errorf("tags/go/index.html = %q, want %q", goTerm, want)
+	}
+	assertDeployHas(t, filepath.Join("tags", "index.html"))
+	assertDeployMissing(t, filepath.Join("tags", "go.html"))
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Redirect map files, written to the deploy root for the formats listed
+// under the site config's "redirect_formats", alongside the stubs:
+//
+//	redirects:
+//	  /old.html: /new.html
+//	redirect_formats: [netlify, nginx]
+//
+// _redirects is Netlify's (and Cloudflare Pages') format, and
+// redirects.map the body of an nginx map block, for
+// "map $uri $redirect { include redirects.map; }".
+const (
+	redirectFormatNetlify = "netlify"
+	redirectFormatNginx   = "nginx"
+	netlifyRedirectsFile  = "_redirects"
+	nginxRedirectsFile    = "redirects.map"
+)
+
+// redirect sends one old URL path to where its content lives now.
+type redirect struct {
+	// from is the old URL path, and output the deploy path of the stub
+	// written there.
+	from, output string
+	// to is where it redirects: a page's URL, or a site redirect's target
+	// as configured.
+	to string
+	// source declares the redirect: a page listing it in "aliases", or
+	// ConfigFile.
+	source string
+}
+
+// loadRedirects collects every page's "aliases" and the site config's
+// "redirects", and claims their stubs' outputs, so walk refuses a source
+// file rendering to the same path.
+func (gen *Generator) loadRedirects() error {
+	var redirects []*redirect
+	for _, page := range gen.index.pages {
+		for _, alias := range pageTerms(page.Page["aliases"]) {
+			redirects = append(redirects, &redirect{from: alias, to: page.URL, source: page.source})
+		}
+	}
+	for from, to := range gen.Config.GetSection("redirects") {
+		target, ok := to.(string)
+		if !ok || target == "" {
+			return fmt.Errorf("%s: redirects: %s must be a URL, got %v", ConfigFile, from, to)
+		}
+		redirects = append(redirects, &redirect{from: from, to: target, source: ConfigFile})
+	}
+	for _, format := range gen.Config.GetStringSlice("redirect_formats") {
+		if format != redirectFormatNetlify && format != redirectFormatNginx {
+			return fmt.Errorf("%s: unknown redirect format %q (want %q or %q)", ConfigFile, format, redirectFormatNetlify, redirectFormatNginx)
+		}
+	}
+	var errs []error
+	for _, r := range redirects {
+		var err error
+		if r.from, r.output, err = redirectOutput(r.from); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.source, err))
+			continue
+		}
+		outputPath := filepath.FromSlash(r.output)
+		if claimant, ok := gen.claimedOutputs[outputPath]; ok {
+			errs = append(errs, fmt.Errorf("%s: redirect from %q already claimed by %s", r.source, r.from, claimant))
+			continue
+		}
+		if gen.claimedOutputs == nil {
+			gen.claimedOutputs = make(map[string]string)
+		}
+		gen.claimedOutputs[outputPath] = r.source
+		gen.redirects = append(gen.redirects, r)
+	}
+	slices.SortFunc(gen.redirects, func(a, b *redirect) int { return strings.Compare(a.from, b.from) })
+	return errors.Join(errs...)
+}
+
+// redirectOutput cleans from, an old URL path, and returns the deploy path
+// its stub goes to: from itself for a file, and its index.html for a
+// directory - one ending in "/", or with no extension at all.
+func redirectOutput(from string) (cleaned, output string, err error) {
+	cleaned = path.Clean("/" + from)
+	if cleaned == "/" {
+		return "", "", fmt.Errorf("redirect from %q can't replace the site's home page", from)
+	}
+	output = strings.TrimPrefix(cleaned, "/")
+	if strings.HasSuffix(from, "/") || path.Ext(cleaned) == "" {
+		cleaned += "/"
+		output += "/index.html"
+	}
+	if !filepath.IsLocal(filepath.FromSlash(output)) {
+		return "", "", fmt.Errorf("redirect from %q must be inside the site", from)
+	}
+	return cleaned, output, nil
+}
+
+// writeRedirects writes every redirect's stub and the configured redirect
+// map files. Like feeds, they're rebuilt on every run but only rewritten
+// when they change, and a redirect nothing declares anymore has no stub
+// written, so reaper removes it.
+func (gen *Generator) writeRedirects() error {
+	var errs []error
+	for _, r := range gen.redirects {
+		if err := gen.writeGenerated(r.output, r.source, ruleRedirect, redirectStub(r.to)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, format := range gen.Config.GetStringSlice("redirect_formats") {
+		var b bytes.Buffer
+		output := netlifyRedirectsFile
+		for _, r := range gen.redirects {
+			if format == redirectFormatNginx {
+				fmt.Fprintf(&b, "%s %s;\n", r.from, r.to)
+			} else {
+				fmt.Fprintf(&b, "%s %s 301\n", r.from, r.to)
+			}
+		}
+		if format == redirectFormatNginx {
+			output = nginxRedirectsFile
+		}
+		if err := gen.writeGenerated(output, ConfigFile, ruleRedirect, b.Bytes()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// redirectStub is the page left at an old URL: it refreshes to to right
+// away, and names it canonical so search engines move their links along.
+func redirectStub(to string) []byte {
+	to = html.EscapeString(to)
+	return []byte(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Redirecting to ` + to + `</title>
+<link rel="canonical" href="` + to + `">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url=` + to + `">
+</head>
+<body>
+<p>This page has moved to <a href="` + to + `">` + to + `</a>.</p>
+</body>
+</html>
+`)
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedirectStubsAndMaps(t *testing.T) {
+	newTestSite(t, "redirect-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	for rel, to := range map[string]string{
+		filepath.Join("old", "path.html"):           "http://example.com/new.html",
+		filepath.Join("2019", "post", "index.html"): "http://example.com/new.html",
+		"moved.html":                        "/new.html",
+		filepath.Join("gone", "index.html"): "https://elsewhere.example/",
+	} {
+		out := readDeploy(t, rel)
+		for _, want := range []string{`<link rel="canonical" href="` + to + `">`, `<meta http-equiv="refresh" content="0; url=` + to + `">`} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s = %q, want %q", rel, out, want)
+			}
+		}
+	}
+	if got, want := readDeploy(t, "_redirects"), "/2019/post/ http://example.com/new.html 301\n/gone/ https://elsewhere.example/ 301\n/moved.html /new.html 301\n/old/path.html http://example.com/new.html 301\n"; got != want {
+		t.Errorf("_redirects = %q, want %q", got, want)
+	}
+	if got, want := readDeploy(t, "redirects.map"), "/2019/post/ http://example.com/new.html;\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("redirects.map = %q, want it to start with %q", got, want)
+	}
+	if entry := readManifest(t).Outputs["old/path.html"]; entry == nil || entry.Source != "new.md" || entry.Rule != ruleRedirect {
+		t.Errorf("manifest entry for old/path.html = %+v, want a redirect from new.md", entry)
+	}
+}
+
+func TestIncrementalReapsDroppedAlias(t *testing.T) {
+	newTestSite(t, "redirect-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	if err := os.WriteFile("new.md", []byte("<!-- aliases: /2019/post -->\n# New\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchFuture(t, "new.md")
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertDeployMissing(t, filepath.Join("old", "path.html"))
+	assertDeployHas(t, filepath.Join("2019", "post", "index.html"))
+
+	// A fresh page keeps its own output, but not the stubs it declared.
+	if err := generate(t); err != nil {
+		t.Fatalf("third generate() error = %v, want nil", err)
+	}
+	assertDeployHas(t, filepath.Join("2019", "post", "index.html"))
+}
+
+func TestRedirectClashesWithSource(t *testing.T) {
+	newTestSite(t, "redirect-site")
+	if err := os.WriteFile("moved.html", []byte("<h1>Still here</h1>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := generate(t)
+	if err == nil || !strings.Contains(err.Error(), "already claimed") {
+		t.Fatalf("generate() error = %v, want a claim error", err)
+	}
+}
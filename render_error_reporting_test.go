@@ -118,7 +118,7 @@ func TestRenderReportsMalformedPageConfigCommentButKeepsGoing(t *testing.T) {
 	if readErr != nil {
 		t.Fatal(readErr)
 	}
-	if !strings.Contains(string(out), "<h1>Hi</h1>") {
+	if !strings.Contains(string(out), `<h1 id="hi">Hi</h1>`) {
 		t.Fatalf("deploy output = %q, want the page body still rendered despite the malformed config comment", out)
 	}
 }
@@ -196,3 +196,56 @@ func TestEFallsBackOnMissingTranslation(t *testing.T) {
 		t.Fatalf("E() = %q, want %q", got, want)
 	}
 }
+
+// The typed variants return config values as decoded, with Resolve's same
+// page, directory, site precedence.
+
+func TestExtraValueReturnsRawValue(t *testing.T) {
+	authors := []interface{}{"Ada", "Grace"}
+	zd := &ZasData{config: ConfigSection{"site": ConfigSection{"authors": authors}}}
+	got, err := zd.ExtraValue("/site/authors")
+	if err != nil {
+		t.Fatalf("ExtraValue() error = %v, want nil", err)
+	}
+	if list, ok := got.([]interface{}); !ok || len(list) != 2 {
+		t.Fatalf("ExtraValue() = %#v, want %#v", got, authors)
+	}
+	if _, err = zd.ExtraValue("/site/missing"); err == nil {
+		t.Fatal("ExtraValue() with a missing key: want error, got nil")
+	}
+}
+
+func TestResolveValuePrecedence(t *testing.T) {
+	zd := &ZasData{
+		Page:      map[interface{}]interface{}{"weight": 3},
+		Directory: ConfigSection{"weight": 2, "draft": true},
+		config:    ConfigSection{"site": ConfigSection{"weight": 1, "links": []interface{}{"a", "b"}}},
+	}
+	for id, want := range map[string]interface{}{"weight": 3, "draft": true, "missing": nil} {
+		if got, err := zd.ResolveValue(id); err != nil || got != want {
+			t.Errorf("ResolveValue(%q) = %v, %v, want %v, nil", id, got, err, want)
+		}
+	}
+	if got, err := zd.ResolveList("links"); err != nil || len(got) != 2 {
+		t.Errorf("ResolveList(links) = %v, %v, want the site's list", got, err)
+	}
+	if got, err := zd.ResolveBool("draft"); err != nil || !got {
+		t.Errorf("ResolveBool(draft) = %v, %v, want true, nil", got, err)
+	}
+}
+
+func TestResolveTypedVariantsRejectOtherTypes(t *testing.T) {
+	zd := &ZasData{Page: map[interface{}]interface{}{"links": "a", "draft": "yes"}}
+	if _, err := zd.ResolveList("links"); err == nil {
+		t.Error("ResolveList() with a string value: want error, got nil")
+	}
+	if _, err := zd.ResolveBool("draft"); err == nil {
+		t.Error("ResolveBool() with a string value: want error, got nil")
+	}
+	if got, err := zd.ResolveList("missing"); err != nil || got != nil {
+		t.Errorf("ResolveList(missing) = %v, %v, want nil, nil", got, err)
+	}
+	if got, err := zd.ResolveBool("missing"); err != nil || got {
+		t.Errorf("ResolveBool(missing) = %v, %v, want false, nil", got, err)
+	}
+}
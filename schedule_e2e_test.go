@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func draftsGen(g *Generator) {
+	g.Drafts = true
+}
+
+func futureGen(g *Generator) {
+	g.Future = true
+}
+
+func TestDraftsAndFuturePagesSkipped(t *testing.T) {
+	newTestSite(t, "schedule-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	assertDeployHas(t, "past.html")
+	for _, rel := range []string{"draft.html", "future.html", "scheduled.html"} {
+		assertDeployMissing(t, rel)
+	}
+	if out, want := readDeploy(t, "index.html"), "<ul><li>Home</li><li>Past</li></ul>"; !strings.Contains(out, want) {
+		t.Fatalf("index.html = %q, want %q", out, want)
+	}
+}
+
+func TestDraftsAndFutureFlags(t *testing.T) {
+	newTestSite(t, "schedule-site")
+	if err := generate(t, draftsGen); err != nil {
+		t.Fatalf("generate(-drafts) error = %v, want nil", err)
+	}
+	assertDeployHas(t, "draft.html")
+	assertDeployMissing(t, "future.html")
+
+	if err := generate(t, futureGen, fullGen); err != nil {
+		t.Fatalf("generate(-future) error = %v, want nil", err)
+	}
+	assertDeployHas(t, "future.html")
+	assertDeployHas(t, "scheduled.html")
+	assertDeployMissing(t, "draft.html")
+}
+
+func TestIncrementalReapsDraftsOnceExcluded(t *testing.T) {
+	newTestSite(t, "schedule-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t, draftsGen); err != nil {
+		t.Fatalf("generate(-drafts) error = %v, want nil", err)
+	}
+	assertDeployHas(t, "draft.html")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	assertDeployMissing(t, "draft.html")
+	if out := readDeploy(t, "index.html"); strings.Contains(out, "Draft") {
+		t.Fatalf("index.html = %q, want the draft no longer listed", out)
+	}
+}
+
+func TestIncrementalLeavesSkippedPagesAlone(t *testing.T) {
+	newTestSite(t, "schedule-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	pages := readManifest(t).Pages
+	for _, source := range []string{"draft.md", "future.md", "scheduled.md"} {
+		if page := pages[source]; page == nil || !page.Skipped {
+			t.Fatalf("manifest page %s = %+v, want it recorded as skipped", source, page)
+		}
+	}
+	if page := pages["past.md"]; page == nil || page.Skipped {
+		t.Fatalf("manifest page past.md = %+v, want it not skipped", page)
+	}
+	verbose := func(g *Generator) { g.Verbose = true }
+	var err error
+	out := captureStderr(t, func() { err = generate(t, verbose) })
+	if err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	for _, source := range []string{"draft.md", "future.md", "scheduled.md"} {
+		if strings.Contains(out, source) {
+			t.Errorf("second run output = %q, want %s left alone", out, source)
+		}
+	}
+
+	// Publishing it is an edit like any other.
+	rewriteFuture(t, "draft.md", "draft: true", "draft: false")
+	if err := generate(t); err != nil {
+		t.Fatalf("third generate() error = %v, want nil", err)
+	}
+	assertDeployHas(t, "draft.html")
+}
+
+func TestIncrementalPublishesScheduledPageOnTime(t *testing.T) {
+	newTestSite(t, "schedule-site")
+	soon := time.Now().Add(time.Second).UTC().Format(time.RFC3339)
+	if err := os.WriteFile("soon.md", []byte("<!-- publish_date: \""+soon+"\" -->\n# Soon\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	assertDeployMissing(t, "soon.html")
+	time.Sleep(time.Until(configTime(soon)) + 100*time.Millisecond)
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertDeployHas(t, "soon.html")
+	if out := readDeploy(t, "index.html"); !strings.Contains(out, "<li>Soon</li>") {
+		t.Fatalf("index.html = %q, want the scheduled page listed", out)
+	}
+}
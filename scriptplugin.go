@@ -108,12 +108,19 @@ func (gen *Generator) handleScriptPlugin(e *goquery.Selection, typ, name string,
 	if err != nil {
 		return fmt.Errorf("invalid %s for script type %q: %w", dataArgsAttr, typ, err)
 	}
+	return gen.pipeToPlugin(e, name, args, e.Text(), fmt.Sprintf("script type %q", typ))
+}
+
+// pipeToPlugin runs zs<name> with args, input on its stdin and stderr
+// passed through, and replaces e with its stdout parsed as HTML. what
+// names the invocation in errors, such as `script type "..."`.
+func (gen *Generator) pipeToPlugin(e *goquery.Selection, name string, args []string, input, what string) error {
 	cmd := exec.Command(PluginPrefix+name, args...)
-	cmd.Stdin = strings.NewReader(e.Text())
+	cmd.Stdin = strings.NewReader(input)
 	cmd.Stderr = os.Stderr
 	out, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("plugin %s%s failed for script type %q: %w", PluginPrefix, name, typ, err)
+		return fmt.Errorf("plugin %s%s failed for %s: %w", PluginPrefix, name, what, err)
 	}
 	// Parse with the tag's own parent as context - the same thing
 	// ReplaceWithHtml does internally - but keep the resulting node slice
@@ -129,10 +136,10 @@ func (gen *Generator) handleScriptPlugin(e *goquery.Selection, typ, name string,
 	// feature must not have.
 	nodes, err := html5.ParseFragment(strings.NewReader(string(out)), e.Get(0).Parent)
 	if err != nil {
-		return fmt.Errorf("plugin %s%s produced unparseable output for script type %q: %w", PluginPrefix, name, typ, err)
+		return fmt.Errorf("plugin %s%s produced unparseable output for %s: %w", PluginPrefix, name, what, err)
 	}
 	if len(nodes) == 0 && strings.TrimSpace(string(out)) != "" {
-		return fmt.Errorf("plugin %s%s produced output that cannot be placed here (script type %q); inside <head> only meta, link, base, style and title are valid", PluginPrefix, name, typ)
+		return fmt.Errorf("plugin %s%s produced output that cannot be placed here (%s); inside <head> only meta, link, base, style and title are valid", PluginPrefix, name, what)
 	}
 	e.ReplaceWithNodes(nodes...)
 	return nil
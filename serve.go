@@ -0,0 +1,370 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultServeAddr is the address Server listens on when Addr is empty.
+// Loopback only: a preview server has no business being reachable from
+// the rest of the network unless asked to be.
+const DefaultServeAddr = "localhost:8080"
+
+// liveReloadPath is the Server-Sent Events endpoint liveReloadScript
+// subscribes to. It lives under a "_zas" prefix so it can't shadow a real
+// deployed file - Zas never deploys anything whose name starts with "_zas"
+// on its own, and a site that does gets it served by every other path.
+const liveReloadPath = "/_zas/livereload"
+
+// liveReloadScript is injected into every HTML response Server sends. The
+// browser reloads the page on any message; the stream carries nothing else,
+// and EventSource reconnects on its own if the server restarts.
+const liveReloadScript = `<script>new EventSource("` + liveReloadPath + `").onmessage = function () { location.reload(); };</script>`
+
+// defaultPollInterval is how often Server rescans the source tree for
+// changes when PollInterval is zero.
+const defaultPollInterval = 500 * time.Millisecond
+
+// Server implements the "serve" subcommand: it generates the site, serves
+// its deploy directory over HTTP, and regenerates it - incrementally, the
+// same way a plain "zas" run does - whenever a source file changes, telling
+// every open browser tab to reload once the new build is in place.
+//
+// It polls the source tree instead of using OS file notifications: Zas has
+// no dependency for those, a site is small enough that a stat walk every
+// PollInterval is cheap, and polling works the same on every platform and
+// filesystem, network mounts and editors that save via rename included.
+type Server struct {
+	// Addr is the TCP address to listen on, DefaultServeAddr if empty.
+	Addr string
+	// Verbose and NoPlugins are passed to every Generator Server runs; see
+	// the Generator fields of the same names.
+	Verbose   bool
+	NoPlugins bool
+	// Drafts and Future are passed to every Generator Server runs too, to
+	// preview pages not published yet.
+	Drafts bool
+	Future bool
+	// PollInterval is how often the source tree is rescanned for changes,
+	// defaultPollInterval if zero.
+	PollInterval time.Duration
+
+	// Guards deployPath, which every rebuild refreshes from the freshly
+	// loaded config while ServeHTTP goroutines read it.
+	mu         sync.Mutex
+	deployPath string
+
+	// snapshot is the source tree's state as of the last build. Only the
+	// single watch goroutine (or a test standing in for it) touches it,
+	// so no mutex.
+	snapshot map[string]fileStamp
+
+	// Guards clients, one channel per open live-reload stream.
+	clientsMu sync.Mutex
+	clients   map[chan struct{}]struct{}
+}
+
+// fileStamp is what scan records per source file: enough to notice an
+// edit, a truncation or a replacement without reading any content.
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+// Run builds the site once, then serves it until the listener fails. A
+// build error after the first one is only reported, never fatal: fixing the
+// offending file triggers the next rebuild, which is the whole point of a
+// preview server. Only a missing or unreadable config - nothing to serve at
+// all - stops Run before it starts listening.
+func (s *Server) Run() error {
+	if err := s.build(); err != nil {
+		if s.getDeployPath() == "" {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+	s.snapshot = s.scan()
+	go s.watch()
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultServeAddr
+	}
+	fmt.Fprintf(os.Stderr, "Serving %s on http://%s/\n", s.getDeployPath(), addr)
+	return http.ListenAndServe(addr, s)
+}
+
+// build runs one incremental generation with a fresh Generator - a
+// Generator caches directory configs and claimed outputs for the length of
+// a single run, so reusing one across rebuilds would serve stale state.
+func (s *Server) build() error {
+	gen := NewGenerator(s.Verbose, false, s.NoPlugins)
+	gen.Drafts, gen.Future = s.Drafts, s.Future
+	err := gen.Run()
+	if deployPath := gen.GetDeployPath(); deployPath != "" {
+		s.mu.Lock()
+		s.deployPath = deployPath
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *Server) getDeployPath() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deployPath
+}
+
+// watch polls the source tree forever, rebuilding on every change.
+func (s *Server) watch() {
+	interval := s.PollInterval
+	if interval == 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.poll()
+	}
+}
+
+// poll rescans the source tree and, if anything changed since the last
+// build, rebuilds and notifies every live-reload client. It reports
+// whether a rebuild happened.
+func (s *Server) poll() bool {
+	current := s.scan()
+	if sameSnapshot(s.snapshot, current) {
+		return false
+	}
+	s.snapshot = current
+	if s.Verbose {
+		fmt.Fprintln(os.Stderr, "~ source changed, regenerating")
+	}
+	if err := s.build(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	s.broadcast()
+	return true
+}
+
+// scan stamps every file under the site root, skipping the deploy
+// directory and ManifestFile - every build rewrites both, so watching them
+// would rebuild forever - and .git, which changes under a site on every
+// commit without any of it ever being deployed.
+func (s *Server) scan() map[string]fileStamp {
+	deployPath := filepath.Clean(s.getDeployPath())
+	stamps := make(map[string]fileStamp)
+	_ = filepath.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// A file deleted mid-scan, or an unreadable directory: the
+			// next scan sees the tree as it settled.
+			return nil
+		}
+		if d.IsDir() {
+			if p != "." && (p == deployPath || p == ".git") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p == ManifestFile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		stamps[p] = fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}
+		return nil
+	})
+	return stamps
+}
+
+// sameSnapshot reports whether a and b stamp exactly the same files
+// identically. A new or removed file changes the length or misses a key.
+func sameSnapshot(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, stamp := range a {
+		if other, ok := b[p]; !ok || other != stamp {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	if s.clients == nil {
+		s.clients = make(map[chan struct{}]struct{})
+	}
+	s.clients[ch] = struct{}{}
+	return ch
+}
+
+func (s *Server) unsubscribe(ch chan struct{}) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	delete(s.clients, ch)
+}
+
+// broadcast wakes every live-reload client. A client that hasn't consumed
+// its previous notification yet already has a reload pending, so the send
+// never blocks on a slow browser.
+func (s *Server) broadcast() {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ServeHTTP serves the deploy directory the way a typical production
+// static host would: a directory serves its index.html, an extensionless
+// path falls back to the same name with ".html" appended (clean URLs), and
+// anything else missing gets a 404 - the site's own 404.html if it
+// deployed one, or a short built-in page otherwise. Every HTML response
+// gets liveReloadScript injected.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == liveReloadPath {
+		s.serveEvents(w, r)
+		return
+	}
+	urlPath := path.Clean("/" + r.URL.Path)
+	name := filepath.Join(s.getDeployPath(), filepath.FromSlash(urlPath))
+	info, err := os.Stat(name)
+	switch {
+	case err == nil && info.IsDir():
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// Relative links inside the directory's index.html only
+			// resolve against it once the URL itself ends in a slash.
+			http.Redirect(w, r, urlPath+"/", http.StatusFound)
+			return
+		}
+		name = filepath.Join(name, "index.html")
+		info, err = os.Stat(name)
+	case err != nil && path.Ext(urlPath) == "":
+		name += ".html"
+		info, err = os.Stat(name)
+	}
+	if err != nil || info.IsDir() {
+		s.serveNotFound(w, r)
+		return
+	}
+	s.serveFile(w, r, name, http.StatusOK)
+}
+
+// serveFile writes the file at name, injecting liveReloadScript when it's
+// HTML. Anything else goes through http.ServeContent, which handles ranges
+// and conditional requests; HTML can't, since injection changes its length.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, name string, status int) {
+	if !hasExtension(name, ".html") {
+		f, err := os.Open(name)
+		if err != nil {
+			s.serveNotFound(w, r)
+			return
+		}
+		defer func() { _ = f.Close() }()
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, name, info.ModTime(), f)
+		return
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	_, _ = w.Write(injectLiveReload(content))
+}
+
+// serveNotFound answers with the site's own deployed 404.html, when there
+// is one, or a minimal page naming the missing path otherwise.
+func (s *Server) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	custom := filepath.Join(s.getDeployPath(), "404.html")
+	if info, err := os.Stat(custom); err == nil && !info.IsDir() {
+		s.serveFile(w, r, custom, http.StatusNotFound)
+		return
+	}
+	page := fmt.Sprintf("<!DOCTYPE html>\n<html>\n<head><title>Not found</title></head>\n<body>\n<h1>Not found</h1>\n<p>Nothing in %s matches <code>%s</code>.</p>\n</body>\n</html>\n",
+		html.EscapeString(s.getDeployPath()), html.EscapeString(r.URL.Path))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write(injectLiveReload([]byte(page)))
+}
+
+// serveEvents holds a Server-Sent Events stream open, sending one message
+// per rebuild until the browser goes away.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			_, _ = fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// injectLiveReload inserts liveReloadScript right before content's last
+// </body> (matched case-insensitively), or appends it when there is none -
+// browsers run a trailing script after </html> just the same.
+func injectLiveReload(content []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(content), []byte("</body>"))
+	if i < 0 {
+		return append(content, liveReloadScript...)
+	}
+	out := make([]byte, 0, len(content)+len(liveReloadScript))
+	out = append(out, content[:i]...)
+	out = append(out, liveReloadScript...)
+	return append(out, content[i:]...)
+}
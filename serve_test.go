@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer builds the "site" fixture through a Server, the same way
+// Server.Run does before it starts listening.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	newTestSite(t, "site")
+	ageSources(t, -time.Hour)
+	s := &Server{}
+	if err := s.build(); err != nil {
+		t.Fatalf("build() error = %v, want nil", err)
+	}
+	s.snapshot = s.scan()
+	return s
+}
+
+func get(t *testing.T, s *Server, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestServeCleanURLFallsBackToHTML(t *testing.T) {
+	s := newTestServer(t)
+	rec := get(t, s, "/about")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /about status = %d, want 200", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "This is the about page.") {
+		t.Fatalf("GET /about body = %q, want about.html's content", body)
+	}
+}
+
+func TestServeDirectoryServesIndex(t *testing.T) {
+	s := newTestServer(t)
+	rec := get(t, s, "/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Welcome") {
+		t.Fatalf("GET / = %d %q, want index.html", rec.Code, rec.Body.String())
+	}
+	rec = get(t, s, "/sub")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/sub/" {
+		t.Fatalf("GET /sub = %d (Location %q), want a redirect to /sub/", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestServeInjectsLiveReloadIntoHTMLOnly(t *testing.T) {
+	s := newTestServer(t)
+	if body := get(t, s, "/about.html").Body.String(); !strings.Contains(body, liveReloadScript+"</body>") {
+		t.Fatalf("GET /about.html = %q, want the live-reload script right before </body>", body)
+	}
+	if body := get(t, s, "/assets/data.json").Body.String(); body != "{\"key\":\"value\"}\n" {
+		t.Fatalf("GET /assets/data.json = %q, want the file untouched", body)
+	}
+}
+
+func TestServeNotFound(t *testing.T) {
+	s := newTestServer(t)
+	rec := get(t, s, "/missing")
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "/missing") {
+		t.Fatalf("GET /missing = %d %q, want a 404 naming the path", rec.Code, rec.Body.String())
+	}
+
+	if err := os.WriteFile("404.md", []byte("# Lost\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !s.poll() {
+		t.Fatal("poll() = false after adding 404.md, want a rebuild")
+	}
+	rec = get(t, s, "/missing")
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `<h1 id="lost">Lost</h1>`) {
+		t.Fatalf("GET /missing = %d %q, want the site's own 404.html", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServePollRebuildsAndNotifies(t *testing.T) {
+	s := newTestServer(t)
+	if s.poll() {
+		t.Fatal("poll() = true with no source change, want false")
+	}
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + liveReloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	events := bufio.NewReader(resp.Body)
+	if line, err := events.ReadString('\n'); err != nil || !strings.HasPrefix(line, ":") {
+		t.Fatalf("first event line = %q, %v; want the connection comment", line, err)
+	}
+
+	if err := os.WriteFile("about.md", []byte("# About\n\nEdited.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchFuture(t, "about.md")
+	if !s.poll() {
+		t.Fatal("poll() = false after editing about.md, want a rebuild")
+	}
+	if out := readDeploy(t, "about.html"); !strings.Contains(out, "Edited.") {
+		t.Fatalf("about.html = %q, want the edited content", out)
+	}
+
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading events: %v", err)
+		}
+		if strings.HasPrefix(line, "data:") {
+			break
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// dataExtensions are the data file formats loadData reads from DataDir.
+var dataExtensions = []string{".yml", ".yaml", ".json", ".csv"}
+
+// Data returns the data file name from DataDir, without its extension and
+// slash-separated (e.g. "team/members" for .zas/data/team/members.yml), as
+// the maps, lists and scalars it decodes to. A CSV file is a list of maps,
+// one per row, keyed by its header row.
+func (zd *ZasData) Data(name string) (interface{}, error) {
+	value, ok := zd.data[path.Clean(strings.TrimPrefix(name, "/"))]
+	if !ok {
+		return nil, fmt.Errorf("data file %q not found in %s", name, DataDir)
+	}
+	return value, nil
+}
+
+// loadData reads every data file in DataDir into gen.data, and sets
+// dataFiles and dataModTime like partials and partialsModTime. A missing
+// DataDir just means no data.
+func (gen *Generator) loadData() {
+	defer gen.wg.Done()
+
+	data := make(map[string]interface{})
+	var errs []error
+	err := filepath.Walk(DataDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if file == DataDir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.ModTime().After(gen.dataModTime) {
+			gen.dataModTime = info.ModTime()
+		}
+		ext := strings.ToLower(filepath.Ext(file))
+		if info.IsDir() || !slices.Contains(dataExtensions, ext) {
+			return nil
+		}
+		rel, err := filepath.Rel(DataDir, file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+		if _, ok := data[name]; ok {
+			errs = append(errs, fmt.Errorf("%s: data file %q is already defined by another format", file, name))
+			return nil
+		}
+		value, err := readDataFile(file, ext)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			return nil
+		}
+		data[name] = value
+		gen.dataFiles = append(gen.dataFiles, file)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err = errors.Join(errs...); err != nil {
+		gen.recordErr(err)
+		return
+	}
+	gen.data = data
+}
+
+// readDataFile decodes the data file at file, in the format ext names.
+func readDataFile(file, ext string) (interface{}, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch ext {
+	case ".json":
+		err = json.Unmarshal(content, &value)
+	case ".csv":
+		value, err = readCSV(content)
+	default:
+		err = yaml.Unmarshal(content, &value)
+	}
+	return value, err
+}
+
+// readCSV decodes content as a list of rows keyed by its header row.
+func readCSV(content []byte) ([]map[string]string, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, key := range header {
+			row[key] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDataFilesInTemplates(t *testing.T) {
+	newTestSite(t, "data-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, "index.html")
+	for _, want := range []string{
+		"<li>Ada (Engineer)</li><li>Grace (Admiral)</li>",
+		"Free: 0; Pro: 9.5;",
+		`<footer><a href="/index.html">Home</a><a href="/blog/">Blog</a></footer>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("index.html = %q, want %q", out, want)
+		}
+	}
+}
+
+func TestDataFileUnknownName(t *testing.T) {
+	newTestSite(t, "data-site")
+	if err := os.WriteFile("about.html", []byte(`<h1>About</h1>{{.Data "nope"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := generate(t)
+	if err == nil || !strings.Contains(err.Error(), `data file "nope" not found`) {
+		t.Fatalf("generate() error = %v, want a not found error", err)
+	}
+}
+
+func TestDataFileNameClash(t *testing.T) {
+	newTestSite(t, "data-site")
+	if err := os.WriteFile(filepath.Join(DataDir, "links.yml"), []byte("[]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := generate(t)
+	if err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Fatalf("generate() error = %v, want a clash error", err)
+	}
+}
+
+func TestIncrementalRebuildsWhenDataFileChanges(t *testing.T) {
+	newTestSite(t, "data-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	members := filepath.Join(DataDir, "team", "members.yml")
+	if err := os.WriteFile(members, []byte("- name: Linus\n  role: Maintainer\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchFuture(t, members)
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out, want := readDeploy(t, "index.html"), "<li>Linus (Maintainer)</li>"; !strings.Contains(out, want) {
+		t.Fatalf("index.html = %q, want %q", out, want)
+	}
+}
+
+func TestSiteParamsAndTypedConfigInTemplates(t *testing.T) {
+	newTestSite(t, "data-site")
+	appendConfig(t, "  title: Example\n  authors: [Ada, Grace]\n")
+	if err := os.WriteFile("about.html", []byte(`<h1>About</h1><p>{{.Site.Params.title}}: {{range .ResolveList "authors"}}{{.}};{{end}}</p>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if out, want := readDeploy(t, "about.html"), "<p>Example: Ada;Grace;</p>"; !strings.Contains(out, want) {
+		t.Fatalf("about.html = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Sitemap and robots.txt outputs, written to the deploy root when the site
+// config has a "sitemap" section:
+//
+//	sitemap:
+//	  exclude: ["404.html", "drafts/*"]
+//	  priority: 0.5
+//	  changefreq: weekly
+//	  robots: true
+const (
+	sitemapOutput = "sitemap.xml"
+	robotsOutput  = "robots.txt"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	XHTML   string       `xml:"xmlns:xhtml,attr,omitempty"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string             `xml:"loc"`
+	LastMod    string             `xml:"lastmod,omitempty"`
+	ChangeFreq string             `xml:"changefreq,omitempty"`
+	Priority   string             `xml:"priority,omitempty"`
+	Alternates []sitemapAlternate `xml:"xhtml:link"`
+}
+
+type sitemapAlternate struct {
+	Rel      string `xml:"rel,attr"`
+	HrefLang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// writeSitemap writes sitemapOutput, and robotsOutput if asked to, when
+// the site config has a "sitemap" section.
+func (gen *Generator) writeSitemap() error {
+	cfg, ok := gen.Config.GetSectionOK("sitemap")
+	if !ok {
+		return nil
+	}
+	content, err := gen.sitemap(cfg)
+	if err != nil {
+		return err
+	}
+	if err = gen.writeGenerated(sitemapOutput, ConfigFile, ruleSitemap, content); err != nil {
+		return err
+	}
+	if robots, _ := cfg.GetBoolOK("robots"); robots {
+		return gen.writeGenerated(robotsOutput, ConfigFile, ruleRobots, gen.robots())
+	}
+	return nil
+}
+
+// sitemap builds sitemapOutput from every published page in the index,
+// but those excluded by cfg's "exclude" patterns (path.Match globs over
+// the page's path, without its leading slash) or by "sitemap: false" in
+// their own config. A page's "sitemap" key can instead be a map overriding
+// cfg's "priority" and "changefreq" for it. Its lastmod is its "lastmod"
+// config key, or else its source's mtime.
+func (gen *Generator) sitemap(cfg ConfigSection) ([]byte, error) {
+	exclude := cfg.GetStringSlice("exclude")
+	for _, pattern := range exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: sitemap exclude %q: %w", ConfigFile, pattern, err)
+		}
+	}
+	var pages PageList
+	for _, page := range gen.index.pages {
+		if pageInSitemap(page, exclude) {
+			pages = append(pages, page)
+		}
+	}
+	alternates := sitemapAlternates(pages, gen.Config.GetSection("site").GetString("language"))
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, page := range pages {
+		overrides, _ := page.Page["sitemap"].(map[string]interface{})
+		entry := sitemapURL{
+			Loc:        page.URL,
+			ChangeFreq: cfg.GetString("changefreq"),
+			Priority:   sitemapPriority(cfg["priority"]),
+		}
+		if changefreq, ok := overrides["changefreq"].(string); ok {
+			entry.ChangeFreq = changefreq
+		}
+		if priority := sitemapPriority(overrides["priority"]); priority != "" {
+			entry.Priority = priority
+		}
+		lastmod := configTime(page.Page["lastmod"])
+		if lastmod.IsZero() {
+			lastmod = page.modTime
+		}
+		entry.LastMod = lastmod.UTC().Format("2006-01-02")
+		entry.Alternates = alternates[page]
+		if len(entry.Alternates) > 0 {
+			set.XHTML = "http://www.w3.org/1999/xhtml"
+		}
+		set.URLs = append(set.URLs, entry)
+	}
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return nil, err
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// pageInSitemap reports whether page belongs in the sitemap.
+func pageInSitemap(page *PageInfo, exclude []string) bool {
+	if listed, ok := page.Page["sitemap"].(bool); ok && !listed {
+		return false
+	}
+	rel := strings.TrimPrefix(page.Path, "/")
+	for _, pattern := range exclude {
+		if matched, _ := path.Match(pattern, rel); matched {
+			return false
+		}
+	}
+	return true
+}
+
+// sitemapPriority formats a configured priority, an int or a float between
+// 0 and 1, or returns "" for anything else.
+func sitemapPriority(value interface{}) string {
+	var priority float64
+	switch value := value.(type) {
+	case int:
+		priority = float64(value)
+	case float64:
+		priority = value
+	default:
+		return ""
+	}
+	if priority < 0 || priority > 1 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", priority)
+}
+
+// sitemapAlternates groups pages that are language variants of each other
+// and returns, for each page in a group of more than one, the hreflang
+// links to every variant in it, itself included. A page is a variant of
+// the default-language page at the same path once its own language
+// prefix is stripped: /es/about.html of /about.html, when /es/about.html
+// resolves to language "es" - the same convention IsHome follows for
+// language home pages.
+func sitemapAlternates(pages PageList, siteLanguage string) map[*PageInfo][]sitemapAlternate {
+	groups := make(map[string]PageList)
+	for _, page := range pages {
+		key := page.Path
+		if prefix := "/" + page.Language; page.Language != siteLanguage && strings.HasPrefix(key, prefix+"/") {
+			key = strings.TrimPrefix(key, prefix)
+		}
+		groups[key] = append(groups[key], page)
+	}
+	alternates := make(map[*PageInfo][]sitemapAlternate)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		links := make([]sitemapAlternate, 0, len(group))
+		for _, page := range group {
+			links = append(links, sitemapAlternate{Rel: "alternate", HrefLang: page.Language, Href: page.URL})
+		}
+		for _, page := range group {
+			alternates[page] = links
+		}
+	}
+	return alternates
+}
+
+// robots builds robotsOutput, allowing everything and pointing at the
+// sitemap.
+func (gen *Generator) robots() []byte {
+	baseURL := strings.TrimRight(gen.Config.GetSection("site").GetString("baseurl"), "/")
+	return []byte("User-agent: *\nAllow: /\n\nSitemap: " + baseURL + "/" + sitemapOutput + "\n")
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"encoding/xml"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readSitemap(t *testing.T) sitemapURLSet {
+	t.Helper()
+	var set sitemapURLSet
+	if err := xml.Unmarshal([]byte(readDeploy(t, sitemapOutput)), &set); err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestSitemapListsPublishedPages(t *testing.T) {
+	newTestSite(t, "sitemap-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	set := readSitemap(t)
+	var locs []string
+	byLoc := make(map[string]sitemapURL)
+	for _, u := range set.URLs {
+		locs = append(locs, u.Loc)
+		byLoc[u.Loc] = u
+	}
+	want := []string{
+		"http://example.com/about.html",
+		"http://example.com/es/about.html",
+		"http://example.com/es/index.html",
+		"http://example.com/index.html",
+	}
+	if !slices.Equal(locs, want) {
+		t.Fatalf("sitemap locs = %v, want %v", locs, want)
+	}
+	about := byLoc["http://example.com/about.html"]
+	if about.LastMod != "2024-05-06" || about.Priority != "0.9" || about.ChangeFreq != "daily" {
+		t.Fatalf("about.html entry = %+v, want its own lastmod, priority and changefreq", about)
+	}
+	index := byLoc["http://example.com/index.html"]
+	if index.Priority != "0.5" || index.ChangeFreq != "weekly" || index.LastMod == "" {
+		t.Fatalf("index.html entry = %+v, want the configured defaults", index)
+	}
+}
+
+func TestSitemapLinksLanguageVariants(t *testing.T) {
+	newTestSite(t, "sitemap-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	out := readDeploy(t, sitemapOutput)
+	for _, want := range []string{
+		`xmlns:xhtml="http://www.w3.org/1999/xhtml"`,
+		`<xhtml:link rel="alternate" hreflang="en" href="http://example.com/about.html"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="es" href="http://example.com/es/about.html"></xhtml:link>`,
+		`<xhtml:link rel="alternate" hreflang="es" href="http://example.com/es/index.html"></xhtml:link>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("sitemap.xml = %s, want %s", out, want)
+		}
+	}
+	// Two variants each of about.html and index.html, each listing both.
+	if got := strings.Count(out, "<xhtml:link "); got != 8 {
+		t.Fatalf("sitemap.xml has %d alternate links, want 8: %s", got, out)
+	}
+}
+
+func TestRobotsPointsAtSitemap(t *testing.T) {
+	newTestSite(t, "sitemap-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	if got := readDeploy(t, robotsOutput); !strings.Contains(got, "\nSitemap: http://example.com/sitemap.xml\n") {
+		t.Fatalf("robots.txt = %q, want it to point at the sitemap", got)
+	}
+}
+
+func TestSitemapReapedWhenDisabled(t *testing.T) {
+	newTestSite(t, "sitemap-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	if err := os.WriteFile(ConfigFile, []byte("zas:\n  layout: .zas/layout.html\n  deploy: .zas/deploy\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertDeployMissing(t, sitemapOutput)
+	assertDeployMissing(t, robotsOutput)
+}
+
+func TestSitemapUnchangedIsNotRewritten(t *testing.T) {
+	newTestSite(t, "sitemap-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	pinDeploy(t, past, sitemapOutput)
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if got := deployModTime(t, sitemapOutput); !got.Equal(past) {
+		t.Fatalf("sitemap.xml was rewritten (mtime %v), want it left alone", got)
+	}
+}
+
+func TestSitemapDoesNotOverwriteSourceFile(t *testing.T) {
+	newTestSite(t, "sitemap-site")
+	if err := os.WriteFile(robotsOutput, []byte("User-agent: *\nDisallow: /\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := generate(t)
+	if err == nil || !strings.Contains(err.Error(), robotsOutput) {
+		t.Fatalf("generate() error = %v, want it to report the conflicting robots.txt", err)
+	}
+	if got := readDeploy(t, robotsOutput); got != "User-agent: *\nDisallow: /\n" {
+		t.Fatalf("robots.txt = %q, want the site's own", got)
+	}
+}
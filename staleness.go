@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Staleness modes, chosen with the zas section's "staleness" key:
+//
+//	zas:
+//	  staleness: hash
+//
+// stalenessMTime, the default, compares modification times, as incremental
+// runs always have. stalenessHash compares content instead: an output is
+// fresh only while the staleness key recorded for it in ManifestFile still
+// matches the one its current source and dependencies hash to, so a fresh
+// clone or a restored CI cache - new mtimes everywhere, same bytes - rebuilds
+// nothing, and neither does a touch or an editor save that changed nothing.
+// The price is reading and hashing every source file on every run, where
+// mtime mode only stats them.
+const (
+	stalenessMTime = "mtime"
+	stalenessHash  = "hash"
+)
+
+// checkStaleness validates the configured staleness mode.
+func (gen *Generator) checkStaleness() error {
+	switch mode := gen.Config.GetZString("staleness"); mode {
+	case "", stalenessMTime, stalenessHash:
+		return nil
+	default:
+		return fmt.Errorf("%s: unknown staleness mode %q (want %q or %q)", ConfigFile, mode, stalenessMTime, stalenessHash)
+	}
+}
+
+// hashStaleness reports whether hash staleness is configured.
+func (gen *Generator) hashStaleness() bool {
+	return gen.Config.GetZString("staleness") == stalenessHash
+}
+
+// fileDigest returns the hex SHA-256 of the file at path, or "" if it
+// can't be read - a missing dependency hashes differently from any real
+// one, which is all a staleness key needs. Every file is read at most once
+// per run: walk hashes sources and shared dependencies, and renderAsync
+// goroutines hash embeds as they compute their outputs' new keys, hence
+// digestMu.
+func (gen *Generator) fileDigest(path string) string {
+	gen.digestMu.Lock()
+	digest, ok := gen.digests[path]
+	gen.digestMu.Unlock()
+	if ok {
+		return digest
+	}
+	if f, err := os.Open(path); err == nil {
+		h := sha256.New()
+		if _, err = io.Copy(h, f); err == nil {
+			digest = hex.EncodeToString(h.Sum(nil))
+		}
+		_ = f.Close()
+	}
+	gen.digestMu.Lock()
+	defer gen.digestMu.Unlock()
+	if gen.digests == nil {
+		gen.digests = make(map[string]string)
+	}
+	gen.digests[path] = digest
+	return digest
+}
+
+// stalenessKey hashes everything source's output depends on into one
+// digest: source's own content and, unless it's only copied, the shared
+// dependency files (layout, partials, config, i18n, data), its directory
+// config and every file it embeds or is laid out with, as listed in
+// entry's deps.
+func (gen *Generator) stalenessKey(source string, entry *manifestEntry) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "source %s\n", gen.fileDigest(source))
+	if ruleFor(source) != ruleCopy {
+		shared := append([]string{gen.Config.GetZString("layout"), ConfigFile, I18nFile}, gen.dirConfigFiles(source)...)
+		shared = append(shared, gen.partials...)
+		shared = append(shared, gen.dataFiles...)
+		for _, dep := range shared {
+			_, _ = fmt.Fprintf(h, "dep %s %s\n", filepath.ToSlash(dep), gen.fileDigest(dep))
+		}
+		for _, dep := range entry.deps() {
+			_, _ = fmt.Fprintf(h, "dep %s %s\n", dep, gen.fileDigest(filepath.FromSlash(dep)))
+		}
+	} else if gen.assetMinifier(source) != nil {
+		// Whether it's minified, at least, is down to config.
+		_, _ = fmt.Fprintf(h, "dep %s %s\n", filepath.ToSlash(ConfigFile), gen.fileDigest(ConfigFile))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// dirConfigFiles returns the DirConfigFiles path's directory config was
+// read from, if any.
+func (gen *Generator) dirConfigFiles(path string) []string {
+	_, _, _ = gen.loadZasDirectoryConfig(path)
+	entry, _ := gen.getCachedDirConfig(filepath.Dir(path))
+	return entry.files
+}
+
+// keyChanged is sourceIsNewer's hash-mode check: source's output is stale
+// unless the previous run recorded it - with the same source, the size the
+// deployed file still has, and a staleness key its current source and
+// dependencies still hash to. The embeds and layout compared are the ones
+// recorded last run; if the source now embeds or picks something else, its
+// own content (or its directory config) changed, so its key already did
+// too.
+func (gen *Generator) keyChanged(source string) bool {
+	output := gen.outputKey(source)
+	entry, ok := gen.prevEntry(output)
+	if !ok || entry.Key == "" || entry.Source != filepath.ToSlash(source) {
+		return true
+	}
+	info, err := os.Stat(gen.BuildDeployPath(filepath.FromSlash(output)))
+	if err != nil || info.Size() != entry.Size {
+		return true
+	}
+	return gen.stalenessKey(source, entry) != entry.Key
+}
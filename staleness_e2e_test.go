@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pinDeploy sets every deploy file's mtime to when, so a later rebuild is
+// visible as a changed mtime regardless of filesystem resolution.
+func pinDeploy(t *testing.T, when time.Time, rels ...string) {
+	t.Helper()
+	for _, rel := range rels {
+		if err := os.Chtimes(filepath.Join(".zas", "deploy", rel), when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newHashStalenessSite(t *testing.T) {
+	t.Helper()
+	newTestSite(t, "site")
+	setZasOption(t, "staleness", stalenessHash)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+}
+
+func TestHashStalenessIgnoresTouchedSources(t *testing.T) {
+	newHashStalenessSite(t)
+	past := time.Now().Add(-time.Hour)
+	pages := []string{"about.html", "index.html", filepath.Join("assets", "data.json")}
+	// What a fresh clone or a restored CI cache looks like: every source
+	// newer than every output, none of them changed.
+	ageSources(t, time.Hour)
+	pinDeploy(t, past, pages...)
+
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	for _, page := range pages {
+		if got := deployModTime(t, page); !got.Equal(past) {
+			t.Fatalf("%s was rebuilt (mtime %v), want it left alone", page, got)
+		}
+	}
+}
+
+func TestHashStalenessRebuildsChangedContent(t *testing.T) {
+	newHashStalenessSite(t)
+	past := time.Now().Add(-time.Hour)
+	pinDeploy(t, past, "about.html", "index.html")
+	// Rewritten, but with an mtime older than the deploy output: mtime
+	// mode would never notice.
+	if err := os.WriteFile(filepath.Join("partials", "nav.html"), []byte("<!-- publish: false -->\n<nav>Edited nav</nav>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	older := past.Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join("partials", "nav.html"), older, older); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, "index.html"); !strings.Contains(out, "Edited nav") {
+		t.Fatalf("index.html = %q, want the edited embed", out)
+	}
+	if got := deployModTime(t, "about.html"); !got.Equal(past) {
+		t.Fatalf("about.html was rebuilt (mtime %v), want it left alone", got)
+	}
+}
+
+func TestHashStalenessRebuildsOnSharedDependencyChange(t *testing.T) {
+	newHashStalenessSite(t)
+	data, err := os.ReadFile(LayoutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(LayoutFile, []byte(strings.Replace(string(data), "<body>", "<body><p>layout-v2</p>", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(LayoutFile, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	if out := readDeploy(t, "about.html"); !strings.Contains(out, "layout-v2") {
+		t.Fatalf("about.html = %q, want the edited layout", out)
+	}
+}
+
+func TestUnknownStalenessModeFails(t *testing.T) {
+	newTestSite(t, "site")
+	setZasOption(t, "staleness", "checksum")
+	if err := generate(t); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("generate() error = %v, want it to reject the unknown mode", err)
+	}
+}
@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Taxonomy is a way of grouping pages, configured under the site config's
+// "taxonomies" section, whose terms pages list under the taxonomy's name
+// in their config:
+//
+//	taxonomies:
+//	  tags:
+//	    path: tags
+//	    layout: tag.html
+//	    index_layout: tags.html
+//	  categories:
+//
+// Every term gets a page at <path>/<slug>.html, and the taxonomy an index
+// of its terms at <path>/index.html, both laid out with the named layout
+// from LayoutsDir (or the default one), where {{.Taxonomy}} and, on term
+// pages, {{.Term}} hold what they list. With pretty URLs on site-wide, a
+// term's page is <path>/<slug>/index.html instead.
+type Taxonomy struct {
+	// Name is the taxonomy's key, both in "taxonomies" and in page config.
+	Name string
+	// Path is the URL path of the taxonomy's index, and URL the same with
+	// the site's base URL.
+	Path string
+	URL  string
+	// Terms are the taxonomy's terms with at least one published page,
+	// sorted by slug.
+	Terms []*Term
+
+	dir, title, layout, indexLayout string
+}
+
+// Term is one of a taxonomy's terms.
+type Term struct {
+	// Name is the term as first written in a page config, and Slug its
+	// URL-safe form, which pages writing it differently ("Go", "go") share.
+	Name string
+	Slug string
+	// Path is the URL path of the term's page, and URL the same with the
+	// site's base URL.
+	Path string
+	URL  string
+	// Pages are the published pages listing the term, sorted by path.
+	Pages PageList
+}
+
+// Terms returns the terms of the taxonomy name, sorted by slug - e.g. for a
+// tag cloud. Like Pages, using it makes the page depend on the page index.
+func (zd *ZasData) Terms(name string) []*Term {
+	if zd.index == nil {
+		return nil
+	}
+	zd.usesIndex = true
+	if taxonomy, ok := zd.index.taxonomies[name]; ok {
+		return taxonomy.Terms
+	}
+	return nil
+}
+
+// loadTaxonomies builds the configured taxonomies' terms from pages.
+func (gen *Generator) loadTaxonomies(pages PageList) (map[string]*Taxonomy, error) {
+	config := gen.Config.GetSection("taxonomies")
+	if len(config) == 0 {
+		return nil, nil
+	}
+	baseURL := strings.TrimRight(gen.Config.GetSection("site").GetString("baseurl"), "/")
+	taxonomies := make(map[string]*Taxonomy, len(config))
+	for name, raw := range config {
+		var section ConfigSection
+		switch raw := raw.(type) {
+		case nil:
+		case ConfigSection:
+			section = raw
+		case map[string]interface{}:
+			section = ConfigSection(raw)
+		default:
+			return nil, fmt.Errorf("%s: taxonomies: %s must be a map, got %T", ConfigFile, name, raw)
+		}
+		taxonomy := &Taxonomy{
+			Name:        name,
+			dir:         path.Clean(section.GetString("path")),
+			title:       section.GetString("title"),
+			layout:      section.GetString("layout"),
+			indexLayout: section.GetString("index_layout"),
+		}
+		if taxonomy.dir == "." {
+			taxonomy.dir = name
+		}
+		if !filepath.IsLocal(filepath.FromSlash(taxonomy.dir)) {
+			return nil, fmt.Errorf("%s: taxonomies: %s: path %q must be inside the site", ConfigFile, name, taxonomy.dir)
+		}
+		if taxonomy.title == "" {
+			taxonomy.title = name
+		}
+		taxonomy.Path = "/" + taxonomy.dir + "/index.html"
+		termPath := func(slug string) string { return "/" + taxonomy.dir + "/" + slug + ".html" }
+		if gen.sitePrettyURLs() {
+			taxonomy.Path = "/" + taxonomy.dir + "/"
+			termPath = func(slug string) string { return "/" + taxonomy.dir + "/" + slug + "/" }
+		}
+		taxonomy.URL = baseURL + taxonomy.Path
+		terms := make(map[string]*Term)
+		for _, page := range pages {
+			for _, termName := range pageTerms(page.Page[name]) {
+				slug := termSlug(termName)
+				if slug == "" {
+					continue
+				}
+				term, ok := terms[slug]
+				if !ok {
+					term = &Term{Name: termName, Slug: slug, Path: termPath(slug)}
+					term.URL = baseURL + term.Path
+					terms[slug] = term
+					taxonomy.Terms = append(taxonomy.Terms, term)
+				}
+				if !slices.Contains(term.Pages, page) {
+					term.Pages = append(term.Pages, page)
+				}
+			}
+		}
+		slices.SortFunc(taxonomy.Terms, func(a, b *Term) int { return strings.Compare(a.Slug, b.Slug) })
+		taxonomies[name] = taxonomy
+	}
+	return taxonomies, nil
+}
+
+// pageTerms returns a page config's terms: a list of strings, or a single
+// one.
+func pageTerms(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var terms []string
+		for _, item := range value {
+			if term, ok := item.(string); ok {
+				terms = append(terms, term)
+			}
+		}
+		return terms
+	}
+	return nil
+}
+
+// termSlug lowercases term and turns every run of characters other than
+// letters and digits into a single "-".
+func termSlug(term string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(term) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// writeTaxonomies writes every term page and taxonomy index. Like feeds,
+// they're rebuilt on every run but only rewritten when they change, and a
+// term no page lists anymore has no page written, so reaper removes it.
+func (gen *Generator) writeTaxonomies() error {
+	var errs []error
+	names := make([]string, 0, len(gen.index.taxonomies))
+	for name := range gen.index.taxonomies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		taxonomy := gen.index.taxonomies[name]
+		if len(taxonomy.Terms) == 0 {
+			continue
+		}
+		if err := gen.writeTaxonomyPage(taxonomy.Path, taxonomy.title, taxonomy.indexLayout, taxonomy, nil); err != nil {
+			errs = append(errs, err)
+		}
+		for _, term := range taxonomy.Terms {
+			if err := gen.writeTaxonomyPage(term.Path, term.Name, taxonomy.layout, taxonomy, term); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeTaxonomyPage lays out the taxonomy page at urlPath, titled title,
+// with the named layout, or the default one if layout is "".
+func (gen *Generator) writeTaxonomyPage(urlPath, title, layout string, taxonomy *Taxonomy, term *Term) error {
+	output := outputOf(urlPath)
+	data := NewZasData(filepath.FromSlash(output), gen)
+	data.Path = urlPath
+	data.Page = map[interface{}]interface{}{"title": title}
+	if layout != "" {
+		data.Page["layout"] = layout
+	}
+	data.Taxonomy = taxonomy
+	data.Term = term
+	tmpl, _, err := gen.layoutFor(&data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", output, err)
+	}
+	var b bytes.Buffer
+	if tmpl != nil {
+		var doc *goquery.Document
+		if doc, err = gen.applyLayout(tmpl, &data); err != nil {
+			return fmt.Errorf("%s: %w", output, err)
+		}
+		if err = gen.writeHTML(&b, doc.Get(0)); err != nil {
+			return fmt.Errorf("%s: %w", output, err)
+		}
+	}
+	return gen.writeGenerated(output, ConfigFile, ruleTaxonomy, b.Bytes())
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaxonomyTermPages(t *testing.T) {
+	newTestSite(t, "taxonomy-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	goTerm := readDeploy(t, filepath.Join("tags", "go.html"))
+	// "go" in posts/again.md comes first by path, so it names the term
+	// "Go" in posts/go.md shares.
+	want := `<h1>Tagged go</h1><ul><li><a href="/posts/again.html">Go again</a></li><li><a href="/posts/go.html">Go on the web</a></li></ul>`
+	if !strings.Contains(goTerm, want) || !strings.Contains(goTerm, "<title>go</title>") {
+		t.Fatalf("tags/go.html = %q, want %q", goTerm, want)
+	}
+	assertDeployHas(t, filepath.Join("tags", "web-dev.html"))
+	assertDeployMissing(t, filepath.Join("tags", "rust.html"))
+	index := readDeploy(t, filepath.Join("tags", "index.html"))
+	if want := `<li><a href="/tags/go.html">go</a> (2)</li><li><a href="/tags/web-dev.html">Web Dev</a> (1)</li>`; !strings.Contains(index, want) {
+		t.Fatalf("tags/index.html = %q, want %q", index, want)
+	}
+	// Without a layout of its own, a taxonomy uses the default one.
+	if out := readDeploy(t, filepath.Join("topics", "programming.html")); !strings.Contains(out, `<p class="default-term">Programming</p>`) {
+		t.Fatalf("topics/programming.html = %q, want the default layout", out)
+	}
+	if out := readDeploy(t, "index.html"); !strings.Contains(out, `<p class="cloud">go web-dev </p>`) {
+		t.Fatalf("index.html = %q, want the tag cloud", out)
+	}
+}
+
+func TestTaxonomyReapsTermsWithoutPages(t *testing.T) {
+	newTestSite(t, "taxonomy-site")
+	ageSources(t, -time.Hour)
+	if err := generate(t); err != nil {
+		t.Fatalf("first generate() error = %v, want nil", err)
+	}
+	if err := os.Remove(filepath.Join("posts", "go.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(t); err != nil {
+		t.Fatalf("second generate() error = %v, want nil", err)
+	}
+	assertDeployMissing(t, filepath.Join("tags", "web-dev.html"))
+	assertDeployMissing(t, "topics")
+	if out := readDeploy(t, filepath.Join("tags", "go.html")); strings.Contains(out, "Go on the web") {
+		t.Fatalf("tags/go.html = %q, want the removed page gone from it", out)
+	}
+	if out := readDeploy(t, "index.html"); !strings.Contains(out, `<p class="cloud">go </p>`) {
+		t.Fatalf("index.html = %q, want it rebuilt with the smaller tag cloud", out)
+	}
+}
+
+func TestTermSlug(t *testing.T) {
+	for term, want := range map[string]string{
+		"Go":            "go",
+		"Web Dev":       "web-dev",
+		"  C++ / Rust ": "c-rust",
+		"Ñandú":         "ñandú",
+		"!!!":           "",
+	} {
+		if got := termSlug(term); got != want {
+			t.Errorf("termSlug(%q) = %q, want %q", term, got, want)
+		}
+	}
+}
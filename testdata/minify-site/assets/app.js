@@ -0,0 +1,4 @@
+// app
+function hello(name) {
+    return "hello " + name;
+}
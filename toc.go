@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"bytes"
+	thtml "html/template"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// headingSelector matches every heading level, in document order.
+const headingSelector = "h1, h2, h3, h4, h5, h6"
+
+// tocMinLevel is the shallowest heading a table of contents lists: h1 is
+// the page's title (see FirstTitle), not one of its sections.
+const tocMinLevel = 2
+
+// TableOfContents lists a page's sections, from its h2 to h6 headings, as
+// {{.TOC}} exposes it: {{.TOC.HTML}} is a ready-made nested list of links,
+// and {{.TOC.Entries}} the same tree to lay out by hand.
+type TableOfContents struct {
+	// Entries are the outermost sections, in page order.
+	Entries []*TOCEntry
+}
+
+// TOCEntry is one heading in a TableOfContents.
+type TOCEntry struct {
+	// Level is the heading's, 2 for an h2 and so on.
+	Level int
+	// ID is the heading's id attribute, and Title its text.
+	ID    string
+	Title string
+	// Children are the headings under this one, up to the next heading
+	// at its level or above.
+	Children []*TOCEntry
+}
+
+// HTML renders toc as nested lists of links to its headings, or nothing
+// for a page without any.
+func (toc TableOfContents) HTML() thtml.HTML {
+	if len(toc.Entries) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	b.WriteString(`<nav class="toc">`)
+	writeTOCEntries(&b, toc.Entries)
+	b.WriteString(`</nav>`)
+	return thtml.HTML(b.String())
+}
+
+func writeTOCEntries(b *bytes.Buffer, entries []*TOCEntry) {
+	b.WriteString("<ul>")
+	for _, entry := range entries {
+		b.WriteString(`<li><a href="#`)
+		b.WriteString(thtml.HTMLEscapeString(entry.ID))
+		b.WriteString(`">`)
+		b.WriteString(thtml.HTMLEscapeString(entry.Title))
+		b.WriteString("</a>")
+		if len(entry.Children) > 0 {
+			writeTOCEntries(b, entry.Children)
+		}
+		b.WriteString("</li>")
+	}
+	b.WriteString("</ul>")
+}
+
+// String makes {{.TOC}} in a page's own template - text/template, which
+// doesn't escape - write the same as {{.TOC.HTML}}.
+func (toc TableOfContents) String() string {
+	return string(toc.HTML())
+}
+
+// headingIDs gives every heading in doc's body without an id one, slugged
+// from its text like a taxonomy term, and suffixed "-1", "-2", ... to keep
+// clear of every id already in doc. It returns the resulting table of
+// contents. Every page gets ids, on purpose, whatever it sets: a link to a
+// heading shouldn't depend on the page it's on opting in.
+func headingIDs(doc *goquery.Document) TableOfContents {
+	taken := make(map[string]bool)
+	doc.Find("[id]").Each(func(_ int, e *goquery.Selection) {
+		taken[e.AttrOr("id", "")] = true
+	})
+	var toc TableOfContents
+	// open holds the innermost entry at each level still taking children.
+	var open []*TOCEntry
+	doc.Find("body").Find(headingSelector).Each(func(_ int, e *goquery.Selection) {
+		title := strings.Join(strings.Fields(e.Text()), " ")
+		if title == "" {
+			// Nothing to link to, or to list.
+			return
+		}
+		id, ok := e.Attr("id")
+		if !ok || id == "" {
+			id = uniqueID(termSlug(title), taken)
+			e.SetAttr("id", id)
+		}
+		level := int(goquery.NodeName(e)[1] - '0')
+		if level < tocMinLevel {
+			return
+		}
+		entry := &TOCEntry{Level: level, ID: id, Title: title}
+		for len(open) > 0 && open[len(open)-1].Level >= level {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			toc.Entries = append(toc.Entries, entry)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		open = append(open, entry)
+	})
+	return toc
+}
+
+// headingAnchors appends to every heading headingIDs gave an id, or found
+// with one, a permalink to itself.
+func headingAnchors(doc *goquery.Document) {
+	doc.Find("body").Find(headingSelector).Each(func(_ int, e *goquery.Selection) {
+		id, ok := e.Attr("id")
+		if !ok || id == "" || strings.TrimSpace(e.Text()) == "" {
+			return
+		}
+		e.AppendHtml(`<a class="anchor" href="#` + thtml.HTMLEscapeString(id) + `" aria-hidden="true">#</a>`)
+	})
+}
+
+// uniqueID returns slug, or "section" for an empty one, suffixed as
+// needed to be missing from taken, and marks it taken.
+func uniqueID(slug string, taken map[string]bool) string {
+	if slug == "" {
+		slug = "section"
+	}
+	id := slug
+	for n := 1; taken[id]; n++ {
+		id = slug + "-" + strconv.Itoa(n)
+	}
+	taken[id] = true
+	return id
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2013 Dario Castañé.
+ * This file is part of Zas.
+ *
+ * Zas is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Zas is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Zas.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package zas
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHeadingIDsAndAnchors(t *testing.T) {
+	newTestSite(t, "toc-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	guide := readDeploy(t, filepath.Join("docs", "guide.html"))
+	for _, want := range []string{
+		"<title>Guide</title>",
+		`<h2 id="install">Install<a aria-hidden="true" class="anchor" href="#install">#</a></h2>`,
+		`<h3 id="on-linux-1">On Linux<a aria-hidden="true" class="anchor" href="#on-linux-1">#</a></h3>`,
+		`<h2 id="install-1">Install`,
+	} {
+		if !strings.Contains(guide, want) {
+			t.Errorf("docs/guide.html = %q, want %q", guide, want)
+		}
+	}
+	// Outside docs/, heading_anchors is off, and an id already written
+	// is kept and never reused.
+	page := readDeploy(t, "page.html")
+	for _, want := range []string{`<h2 id="custom">Custom id</h2>`, `<h2 id="custom-1">Custom</h2>`, `<h4 id="skipping-a-level">Skipping a level</h4>`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page.html = %q, want %q", page, want)
+		}
+	}
+}
+
+func TestTOCInLayoutAndPage(t *testing.T) {
+	newTestSite(t, "toc-site")
+	if err := generate(t); err != nil {
+		t.Fatalf("generate() error = %v, want nil", err)
+	}
+	guide := readDeploy(t, filepath.Join("docs", "guide.html"))
+	toc := `<nav class="toc"><ul><li><a href="#install">Install</a><ul><li><a href="#on-linux">On Linux</a></li><li><a href="#on-macos">On macOS</a></li></ul></li><li><a href="#usage">Usage</a><ul><li><a href="#on-linux-1">On Linux</a></li></ul></li><li><a href="#install-1">Install</a></li></ul></nav>`
+	if want := "<aside>" + toc + "</aside>"; !strings.Contains(guide, want) {
+		t.Errorf("docs/guide.html = %q, want the layout's %q", guide, want)
+	}
+	if strings.Count(guide, toc) != 2 {
+		t.Errorf("docs/guide.html = %q, want the page's own {{.TOC}} to match the layout's", guide)
+	}
+	if want := `<p class="sections">install(2) usage(1) install-1(0) </p>`; !strings.Contains(guide, want) {
+		t.Errorf("docs/guide.html = %q, want %q", guide, want)
+	}
+	if page, want := readDeploy(t, "page.html"), `<li><a href="#custom-1">Custom</a><ul><li><a href="#skipping-a-level">Skipping a level</a></li></ul></li>`; !strings.Contains(page, want) {
+		t.Errorf("page.html = %q, want a skipped level nested under its nearest heading: %q", page, want)
+	}
+}
+
+func TestUniqueID(t *testing.T) {
+	taken := map[string]bool{"intro": true}
+	for _, tc := range []struct{ slug, want string }{
+		{"intro", "intro-1"},
+		{"intro", "intro-2"},
+		{"", "section"},
+		{"", "section-1"},
+	} {
+		if got := uniqueID(tc.slug, taken); got != tc.want {
+			t.Errorf("uniqueID(%q) = %q, want %q", tc.slug, got, tc.want)
+		}
+	}
+}